@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/client"
+)
+
+const (
+	// commandPrefix marks chat input lines handled as commands instead of questions
+	commandPrefix = "/"
+	// historySnippetLen is the number of characters of a message shown by /history
+	historySnippetLen = 80
+)
+
+const chatCommandsHelp = `Commands:
+  /history                          show the active branch with message numbers
+  /fork <number> [--new-session]    continue from an earlier message in a new branch or session
+  /edit <number> <text>             edit an earlier question and get a new answer
+  /regenerate                       get a new version of the last answer
+  /alternatives <number>            list the versions of a message
+  /switch <number>                  continue the conversation from another version of a message
+  /star <number>, /unstar <number>  mark a message for the fine-tuning dataset export
+  /attach <path>                    upload a file and attach it to the next question
+  /files                            list the uploaded files
+  /rmfile <id>                      delete an uploaded file
+  /help                             show this help`
+
+// runChatCommand handles a command typed in the chat prompt and returns the session to continue with.
+// Uploaded files are added to the attachments of the next question
+func runChatCommand(ctx context.Context, gcc *client.Client, session *chat.Session, attachments *[]chat.File, line string) (*chat.Session, error) {
+	args := strings.Fields(strings.TrimPrefix(line, commandPrefix))
+	if len(args) == 0 {
+		return session, errors.New("empty command, see /help")
+	}
+
+	switch args[0] {
+	case "help":
+		fmt.Println(chatCommandsHelp)
+		return session, nil
+	case "history":
+		return session, printHistory(gcc, session)
+	case "fork":
+		return forkCommand(gcc, session, args[1:])
+	case "edit":
+		return session, editCommand(ctx, gcc, session, line)
+	case "regenerate":
+		return session, gcc.Regenerate(ctx, session.ID, printEvent)
+	case "alternatives":
+		return session, alternativesCommand(gcc, session, args[1:])
+	case "switch":
+		return session, switchCommand(gcc, session, args[1:])
+	case "star", "unstar":
+		return session, starCommand(gcc, session, args[1:], args[0] == "star")
+	case "attach":
+		return session, attachCommand(ctx, gcc, attachments, line)
+	case "files":
+		return session, filesCommand(ctx, gcc)
+	case "rmfile":
+		return session, rmfileCommand(ctx, gcc, attachments, args[1:])
+	default:
+		return session, fmt.Errorf("unknown command %q, see /help", args[0])
+	}
+}
+
+// printHistory prints the active branch of the session.
+// Messages having alternative versions are marked with the version number
+func printHistory(gcc *client.Client, session *chat.Session) error {
+	branch, err := gcc.Branch(session.ID)
+	if err != nil {
+		return err
+	}
+	messages, err := gcc.MessageStorage.ReadBySessionID(session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read session messages from storage: %w", err)
+	}
+
+	// Messages sharing a parent are versions of each other
+	versions := make(map[string][]string)
+	for _, m := range messages {
+		versions[m.ParentID] = append(versions[m.ParentID], m.ID)
+	}
+
+	for _, m := range branch {
+		marker := ""
+		if v := versions[m.ParentID]; len(v) > 1 {
+			marker = fmt.Sprintf(" (%d/%d)", slices.Index(v, m.ID)+1, len(v))
+		}
+		fmt.Printf("#%d %s%s: %s\n", m.Seq, m.Role, marker, snippet(m.Content))
+	}
+	return nil
+}
+
+// forkCommand continues the conversation from an earlier message
+func forkCommand(gcc *client.Client, session *chat.Session, args []string) (*chat.Session, error) {
+	if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "--new-session") {
+		return session, errors.New("usage: /fork <number> [--new-session]")
+	}
+	message, err := messageBySeq(gcc, session.ID, args[0])
+	if err != nil {
+		return session, err
+	}
+
+	if len(args) == 2 {
+		forked, err := gcc.ForkSession(session.ID, message.ID, session.Name+" (fork)")
+		if err != nil {
+			return session, err
+		}
+		fmt.Printf("forked into new session %q from message #%d\n", forked.Name, message.Seq)
+		return forked, nil
+	}
+
+	if err := gcc.Fork(session.ID, message.ID); err != nil {
+		return session, err
+	}
+	fmt.Printf("new branch started after message #%d\n", message.Seq)
+	return session, nil
+}
+
+// editCommand replaces an earlier question with the new text and requests a new answer.
+// The text is taken from the raw line to keep its whitespace
+func editCommand(ctx context.Context, gcc *client.Client, session *chat.Session, line string) error {
+	args := strings.Fields(line)
+	if len(args) < 3 {
+		return errors.New("usage: /edit <number> <text>")
+	}
+	message, err := messageBySeq(gcc, session.ID, args[1])
+	if err != nil {
+		return err
+	}
+	_, rest, _ := strings.Cut(line, args[1])
+	return gcc.EditMessage(ctx, session.ID, message.ID, strings.TrimSpace(rest), printEvent)
+}
+
+// alternativesCommand prints all versions of a message
+func alternativesCommand(gcc *client.Client, session *chat.Session, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: /alternatives <number>")
+	}
+	message, err := messageBySeq(gcc, session.ID, args[0])
+	if err != nil {
+		return err
+	}
+	alternatives, err := gcc.Alternatives(session.ID, message.ID)
+	if err != nil {
+		return err
+	}
+	for _, m := range alternatives {
+		fmt.Printf("#%d %s: %s\n", m.Seq, m.Role, snippet(m.Content))
+	}
+	return nil
+}
+
+// switchCommand makes the branch going through the given message active
+func switchCommand(gcc *client.Client, session *chat.Session, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: /switch <number>")
+	}
+	message, err := messageBySeq(gcc, session.ID, args[0])
+	if err != nil {
+		return err
+	}
+	if err := gcc.Switch(session.ID, message.ID); err != nil {
+		return err
+	}
+	return printHistory(gcc, session)
+}
+
+// starCommand stars or unstars a message
+func starCommand(gcc *client.Client, session *chat.Session, args []string, starred bool) error {
+	if len(args) != 1 {
+		return errors.New("usage: /star <number> or /unstar <number>")
+	}
+	message, err := messageBySeq(gcc, session.ID, args[0])
+	if err != nil {
+		return err
+	}
+	return gcc.MessageStorage.SetStarred(message.ID, starred)
+}
+
+// messageBySeq returns the session message with the given sequence number
+func messageBySeq(gcc *client.Client, sessionID, arg string) (*chat.Message, error) {
+	seq, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message number %q", arg)
+	}
+	messages, err := gcc.MessageStorage.ReadBySessionID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session messages from storage: %w", err)
+	}
+	for i := range messages {
+		if messages[i].Seq == seq {
+			return &messages[i], nil
+		}
+	}
+	return nil, fmt.Errorf("message #%d not found", seq)
+}
+
+// snippet returns the first line of the content shortened for listings
+func snippet(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	if r := []rune(line); len(r) > historySnippetLen {
+		return string(r[:historySnippetLen]) + "..."
+	}
+	return line
+}
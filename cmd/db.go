@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/storage"
+)
+
+// runDB handles the `db` subcommands
+func runDB(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: db migrate [--dry-run] | db check [--repair | --delete]")
+	}
+
+	switch args[0] {
+	case "migrate":
+		return runDBMigrate(cfg, args[1:])
+	case "check":
+		return runDBCheck(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown db command %q", args[0])
+	}
+}
+
+// runDBMigrate applies pending schema migrations or lists them with --dry-run
+func runDBMigrate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("db migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := storage.Open(cfg.DatabaseDSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	current, err := storage.SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	migrations, err := storage.Migrate(db, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("schema version: %d\n", current)
+	if len(migrations) == 0 {
+		fmt.Println("database is up to date")
+		return nil
+	}
+	for _, m := range migrations {
+		if *dryRun {
+			fmt.Printf("pending: %04d_%s\n", m.Version, m.Name)
+		} else {
+			fmt.Printf("applied: %04d_%s\n", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// runDBCheck finds messages whose session no longer exists and optionally repairs them
+func runDBCheck(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("db check", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "restore a placeholder session for orphaned messages")
+	deleteOrphans := fs.Bool("delete", false, "delete orphaned messages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *repair && *deleteOrphans {
+		return errors.New("--repair and --delete are mutually exclusive")
+	}
+
+	db, err := openDatabase(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	orphans, err := storage.FindOrphans(db)
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		fmt.Println("no orphaned messages found")
+		return nil
+	}
+	for _, o := range orphans {
+		fmt.Printf("orphaned: %d messages of missing session %s\n", o.Messages, o.SessionID)
+	}
+
+	switch {
+	case *repair:
+		if err := storage.RestoreOrphans(db, orphans); err != nil {
+			return err
+		}
+		fmt.Printf("restored %d sessions\n", len(orphans))
+	case *deleteOrphans:
+		deleted, err := storage.DeleteOrphans(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deleted %d messages\n", deleted)
+	}
+	return nil
+}
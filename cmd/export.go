@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/export"
+	"github.com/gennadis/gigachatui/storage"
+)
+
+// exportDirPerm is the permission of the directory created for bulk exports
+const exportDirPerm = 0o750
+
+// runExport exports one session to a file or stdout, or every session to a directory
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := fs.String("format", string(export.FormatMarkdown), "export format: md, html, json or txt")
+	output := fs.String("output", "", "output file, stdout by default")
+	all := fs.Bool("all", false, "export every session into the --dir directory")
+	dir := fs.String("dir", ".", "output directory for --all")
+	allBranches := fs.Bool("all-branches", false, "export every message version instead of the active branch only")
+	fineTune := fs.Bool("finetune", false, "export the sessions, or all with --all, as a fine-tuning JSONL dataset")
+	starred := fs.Bool("starred", false, "with --finetune, export the branches ending at starred messages")
+	system := fs.Bool("system", false, "with --finetune, keep system prompts")
+	dropFailed := fs.Bool("drop-failed", false, "with --finetune, drop questions without an answer and empty answers")
+	validation := fs.Float64("validation", 0, "with --finetune, fraction of examples written to valid.jsonl in --dir")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: export <session> [flags] | export --all [--dir <dir>] [flags]")
+		fmt.Fprintln(fs.Output(), "       export --finetune [<session>...] [--all | --starred] [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	sessionsStore, messagesStore, err := storage.NewStores(db)
+	if err != nil {
+		return err
+	}
+
+	if *fineTune {
+		if *validation < 0 || *validation >= 1 {
+			return errors.New("--validation must be in [0, 1)")
+		}
+		examples, err := fineTuneExamples(sessionsStore, messagesStore, positional, *all, *starred,
+			export.FineTuneOptions{IncludeSystem: *system, DropFailed: *dropFailed})
+		if err != nil {
+			return err
+		}
+		return writeFineTune(examples, *validation, *output, *dir)
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		return exportAll(sessionsStore, messagesStore, format, *dir, *allBranches)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("session id or name is required")
+	}
+
+	session, err := findSession(sessionsStore, positional[0])
+	if err != nil {
+		return err
+	}
+	doc, err := exportDocument(messagesStore, session, *allBranches)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	return export.Write(w, format, doc)
+}
+
+// fineTuneExamples collects training examples from the starred messages or from the active branches of sessions
+func fineTuneExamples(sessions storage.SessionStore, messages storage.MessageStore, refs []string, all, starred bool,
+	opts export.FineTuneOptions) ([]export.FineTuneExample, error) {
+	var examples []export.FineTuneExample
+	add := func(key string, branch []chat.Message) {
+		if e, ok := export.NewFineTuneExample(key, branch, opts); ok {
+			examples = append(examples, e)
+		}
+	}
+
+	if starred {
+		if len(refs) > 0 || all {
+			return nil, errors.New("--starred cannot be combined with sessions or --all")
+		}
+		stars, err := messages.ReadStarred()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range stars {
+			branch, err := messages.ReadBranch(m.ID)
+			if err != nil {
+				return nil, err
+			}
+			add(m.ID, branch)
+		}
+		return examples, nil
+	}
+
+	var selected []chat.Session
+	switch {
+	case all:
+		var err error
+		if selected, err = sessions.Read(); err != nil {
+			return nil, err
+		}
+	case len(refs) == 0:
+		return nil, errors.New("sessions, --all or --starred are required")
+	default:
+		for _, ref := range refs {
+			session, err := findSession(sessions, ref)
+			if err != nil {
+				return nil, err
+			}
+			selected = append(selected, session)
+		}
+	}
+
+	for _, session := range selected {
+		doc, err := exportDocument(messages, session, false)
+		if err != nil {
+			return nil, err
+		}
+		add(session.ID, doc.Messages)
+	}
+	return examples, nil
+}
+
+// writeFineTune writes the examples to the output, or splits them into train.jsonl and valid.jsonl in the directory
+func writeFineTune(examples []export.FineTuneExample, validation float64, output, dir string) error {
+	if validation == 0 {
+		var w io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create export file: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		return export.WriteFineTune(w, examples)
+	}
+
+	if err := os.MkdirAll(dir, exportDirPerm); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	train, valid := export.SplitFineTune(examples, validation)
+	sets := []struct {
+		name     string
+		examples []export.FineTuneExample
+	}{{"train.jsonl", train}, {"valid.jsonl", valid}}
+	for _, set := range sets {
+		path := filepath.Join(dir, set.name)
+		f, err := os.Create(path) // #nosec G304 -- the path is built from the user supplied directory
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		if err := export.WriteFineTune(f, set.examples); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d examples\n", path, len(set.examples))
+	}
+	return nil
+}
+
+// exportAll exports every session into its own file in the directory
+func exportAll(sessions storage.SessionStore, messages storage.MessageStore, format export.Format, dir string, allBranches bool) error {
+	all, err := sessions.Read()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, exportDirPerm); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	for _, session := range all {
+		doc, err := exportDocument(messages, session, allBranches)
+		if err != nil {
+			return err
+		}
+		path, err := export.WriteFile(dir, format, doc)
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	fmt.Printf("exported %d sessions\n", len(all))
+	return nil
+}
+
+// exportDocument reads the messages of the session to export.
+// By default only the active branch is exported
+func exportDocument(messages storage.MessageStore, session chat.Session, allBranches bool) (*export.Document, error) {
+	doc := &export.Document{Session: session}
+	var err error
+	switch {
+	case allBranches:
+		doc.Messages, err = messages.ReadBySessionID(session.ID)
+	case session.HeadID != "":
+		doc.Messages, err = messages.ReadBranch(session.HeadID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages of session %s: %w", session.ID, err)
+	}
+	return doc, nil
+}
+
+// findSession returns the session by its id, unique id prefix or name
+func findSession(sessions storage.SessionStore, ref string) (chat.Session, error) {
+	if session, err := sessions.Get(ref); err == nil {
+		return session, nil
+	}
+
+	all, err := sessions.Read()
+	if err != nil {
+		return chat.Session{}, err
+	}
+	var found []chat.Session
+	for _, s := range all {
+		if s.Name == ref || strings.HasPrefix(s.ID, ref) {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return chat.Session{}, fmt.Errorf("session %q: %w", ref, storage.ErrNotFound)
+	case 1:
+		return found[0], nil
+	default:
+		return chat.Session{}, fmt.Errorf("session %q is ambiguous, %d sessions match", ref, len(found))
+	}
+}
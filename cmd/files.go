@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/client"
+)
+
+// attachCommand uploads the file and adds it to the attachments of the next question.
+// The path is taken from the raw line to keep its whitespace
+func attachCommand(ctx context.Context, gcc *client.Client, attachments *[]chat.File, line string) error {
+	path := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), commandPrefix+"attach"))
+	if path == "" {
+		return errors.New("usage: /attach <path>")
+	}
+	file, err := gcc.UploadFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	*attachments = append(*attachments, *file)
+	fmt.Printf("attached %s (%d bytes) as %s to the next question\n", file.Filename, file.Bytes, file.ID)
+	return nil
+}
+
+// filesCommand lists the files uploaded to the API, marking the ones uploaded from here with their local paths
+func filesCommand(ctx context.Context, gcc *client.Client) error {
+	remote, err := gcc.API.Files(ctx)
+	if err != nil {
+		return err
+	}
+	local, err := gcc.FileStorage.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read files from storage: %w", err)
+	}
+	paths := make(map[string]string, len(local))
+	for _, f := range local {
+		paths[f.ID] = f.Path
+	}
+
+	if len(remote) == 0 {
+		fmt.Println("no uploaded files")
+		return nil
+	}
+	for _, f := range remote {
+		fmt.Printf("%s  %-30s %10d bytes  %s", f.ID, f.Filename, f.Bytes, time.Unix(f.CreatedAt, 0).Local().Format(time.DateTime))
+		if path, ok := paths[f.ID]; ok {
+			fmt.Printf("  from %s", path)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// rmfileCommand deletes the uploaded file and drops it from the attachments of the next question
+func rmfileCommand(ctx context.Context, gcc *client.Client, attachments *[]chat.File, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: /rmfile <id>")
+	}
+	if err := gcc.DeleteFile(ctx, args[0]); err != nil {
+		return err
+	}
+	*attachments = slices.DeleteFunc(*attachments, func(f chat.File) bool { return f.ID == args[0] })
+	fmt.Printf("deleted file %s\n", args[0])
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// inlineImageTerminals are the terminals showing images sent with the inline images protocol of iTerm2
+var inlineImageTerminals = map[string]bool{
+	"iTerm.app": true,
+	"WezTerm":   true,
+}
+
+// printImage prints the path of the image generated by the model, with the image itself if the terminal shows images
+func printImage(path string) {
+	fmt.Printf("\n[image %s]\n", path)
+	if !inlineImageTerminals[os.Getenv("TERM_PROGRAM")] {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	name := base64.StdEncoding.EncodeToString([]byte(filepath.Base(path)))
+	fmt.Printf("\x1b]1337;File=name=%s;size=%d;inline=1:%s\a\n", name, len(data), base64.StdEncoding.EncodeToString(data))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/importer"
+	"github.com/gennadis/gigachatui/storage"
+)
+
+// runImport imports conversations from a ChatGPT export or OpenAI style JSONL file
+func runImport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	formatName := fs.String("format", string(importer.FormatAuto), "file format: auto, chatgpt or openai")
+	flatten := fs.Bool("flatten", false, "import only the current branch of branching conversations")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: import <file> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("file to import is required")
+	}
+
+	f, err := os.Open(positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer f.Close()
+
+	conversations, err := importer.Parse(f, importer.Format(*formatName), importer.Options{Flatten: *flatten})
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	sessionsStore, _, err := storage.NewStores(db)
+	if err != nil {
+		return err
+	}
+
+	messages := 0
+	for i := range conversations {
+		if err := importer.Save(sessionsStore, &conversations[i]); err != nil {
+			return fmt.Errorf("failed to import conversation %q: %w", conversations[i].Session.Name, err)
+		}
+		messages += len(conversations[i].Messages)
+	}
+	fmt.Printf("imported %d conversations with %d messages\n", len(conversations), messages)
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/client"
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/inline"
+)
+
+// newQuestion makes the question of the prompt with the files it references appended,
+// printing the included and skipped ones
+func newQuestion(cfg *config.Config, prompt string) (client.Question, error) {
+	expanded, err := inline.Expand(prompt, inline.Options{
+		MaxFileSize: cfg.InlineMaxFileSize,
+		TokenBudget: int(cfg.InlineTokenBudget),
+	})
+	if err != nil {
+		return client.Question{}, fmt.Errorf("failed to inline files: %w", err)
+	}
+
+	for _, s := range expanded.Skipped {
+		fmt.Printf("[skipped %s: %s]\n", s.Path, s.Reason)
+	}
+	if len(expanded.Files) > 0 {
+		fmt.Printf("[inlined %s]\n", strings.Join(expanded.Files, ", "))
+	}
+	return client.Question{Content: expanded.Content, InlinedFiles: expanded.Files}, nil
+}
@@ -3,35 +3,38 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"strings"
 
+	"github.com/gennadis/gigachatui/gigachat"
 	"github.com/gennadis/gigachatui/internal/auth"
 	"github.com/gennadis/gigachatui/internal/chat"
 	"github.com/gennadis/gigachatui/internal/client"
 	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/httprecord"
 	"github.com/gennadis/gigachatui/storage"
+	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 )
 
-const databaseFilePath = "./sqlite.db"
-
 func main() {
-	ctx := context.Background()
-
-	// Load environment variables from .env file
-	if err := godotenv.Load(".env"); err != nil {
-		log.Fatalf("failed to load `.env` file: %v", err)
+	// Load environment variables of the selected profile first, they take precedence over .env
+	if profile := os.Getenv("PROFILE"); profile != "" {
+		if err := godotenv.Load(".env." + profile); err != nil {
+			log.Fatalf("failed to load `.env.%s` file: %v", profile, err)
+		}
 	}
 
-	// Retrieve client ID and client secret from environment variables
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
-	if clientID == "" || clientSecret == "" {
-		log.Fatalf("CLIENT_ID or CLIENT_SECRET must be set in the environment")
+	// Load environment variables from .env file, if there is one
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("failed to load `.env` file: %v", err)
 	}
 
 	// Initialize configuration
@@ -40,41 +43,66 @@ func main() {
 		log.Fatalf("failed to create config: %v", err)
 	}
 
-	// Initialize database
-	dataDB, err := storage.NewSqliteDB(databaseFilePath)
-	if err != nil {
-		log.Fatalf("failed to create database file %s: %v", databaseFilePath, err)
+	// Dispatch subcommands, the interactive chat is the default one
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "db":
+			if err := runDB(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("failed to run db command: %v", err)
+			}
+			return
+		case "export":
+			if err := runExport(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("failed to run export command: %v", err)
+			}
+			return
+		case "import":
+			if err := runImport(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("failed to run import command: %v", err)
+			}
+			return
+		case "serve":
+			if err := runServe(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("failed to run serve command: %v", err)
+			}
+			return
+		case "mcp-serve":
+			if err := runMCPServe(cfg, os.Args[2:]); err != nil {
+				log.Fatalf("failed to run mcp-serve command: %v", err)
+			}
+			return
+		}
 	}
-	slog.Debug("database filepath", "path", databaseFilePath)
+	runChat(cfg)
+}
 
-	// Make store and load sessions
-	sessionsStore, err := storage.NewSessions(dataDB)
-	if err != nil {
-		log.Fatalf("failed to make sessions store: %v", err)
-	}
-	// Make store and load messages
-	messagesStore, err := storage.NewMessages(dataDB)
+// runChat runs the interactive chat loop
+func runChat(cfg *config.Config) {
+	ctx := context.Background()
+
+	noHistory := flag.Bool("no-history", false, "keep the conversation in memory only, without writing it to the database")
+	recording := addRecordFlags(flag.CommandLine)
+	flag.Parse()
+
+	transport, err := recording.transport()
 	if err != nil {
-		log.Fatalf("failed to make messages store: %v", err)
+		log.Fatalf("failed to set up recording: %v", err)
 	}
 
-	authManager, err := auth.NewManager(ctx, clientID, clientSecret)
+	// Make history stores
+	st, err := openStores(cfg, *noHistory)
 	if err != nil {
-		log.Fatalf("failed to init auth manager: %v", err)
+		log.Fatalf("failed to make storage: %v", err)
 	}
 
 	// Create a new GigaChat client
-	gcc, err := client.NewClient(*cfg, *authManager, *sessionsStore, *messagesStore)
+	gcc, err := newClient(ctx, cfg, transport, st)
 	if err != nil {
 		log.Fatalf("failed to create GigaChat API client: %v", err)
 	}
-
-	// Run the authentication handler in a separate goroutine
-	wg := gcc.AuthManager.Run(ctx)
-	go func() {
-		defer close(gcc.AuthManager.ErrorChan)
-		wg.Wait()
-	}()
+	if err := registerTools(ctx, gcc, cfg); err != nil {
+		log.Fatalf("failed to register tools: %v", err)
+	}
 
 	// Prompt user for chat name
 	chatName, err := promptUser("Enter a chat name: ")
@@ -88,19 +116,162 @@ func main() {
 		log.Fatalf("failed to write session to storage: %s", err)
 	}
 
-	// Main loop to handle user questions
+	// Main loop to handle user questions, attached files are sent with the next one
+	var attachments []chat.File
 	for {
 		userPromt, err := promptUser("\nAsk a question: ")
 		if err != nil {
 			log.Fatalf("failed to handle user question prompt: %v", err)
 		}
 
-		if err := gcc.RequestCompletion(ctx, session.ID, userPromt); err != nil {
+		if strings.HasPrefix(userPromt, commandPrefix) {
+			if session, err = runChatCommand(ctx, gcc, session, &attachments, userPromt); err != nil {
+				slog.Error("failed to handle chat command", "error", err)
+			}
+			continue
+		}
+
+		question, err := newQuestion(cfg, userPromt)
+		if err != nil {
+			slog.Error("failed to make question", "error", err)
+			continue
+		}
+		for _, f := range attachments {
+			question.Attachments = append(question.Attachments, f.ID)
+		}
+		attachments = nil
+		if _, err := gcc.Ask(ctx, session.ID, question, printEvent); err != nil {
 			slog.Error("failed to handle user promt completion", "error", err)
 		}
 	}
 }
 
+// printEvent prints the answer text as it is streamed, the functions called by the assistant and the generated images
+func printEvent(e client.Event) error {
+	switch e.Type {
+	case client.EventDelta:
+		fmt.Print(e.Delta)
+	case client.EventFunctionCall:
+		fmt.Printf("[calling %s %s]\n", e.Message.FunctionCall.Name, e.Message.FunctionCall.Arguments)
+	case client.EventImage:
+		printImage(e.Image)
+	}
+	return nil
+}
+
+// newClient authenticates with the credentials from the environment
+// and creates a GigaChat client whose access token is rotated in the background.
+// Both the authentication and the API requests go through the transport unless it is nil
+func newClient(ctx context.Context, cfg *config.Config, transport http.RoundTripper, st *stores) (*client.Client, error) {
+	// Retrieve client ID and client secret from environment variables
+	clientID := os.Getenv("CLIENT_ID")
+	clientSecret := os.Getenv("CLIENT_SECRET")
+	if _, ok := transport.(*httprecord.Replayer); ok {
+		// Replayed runs never reach the authentication API
+		clientID, clientSecret = httprecord.Redacted, httprecord.Redacted
+	}
+	if clientID == "" || clientSecret == "" {
+		return nil, errors.New("CLIENT_ID or CLIENT_SECRET must be set in the environment")
+	}
+
+	var (
+		httpClient *http.Client
+		apiOpts    []gigachat.Option
+	)
+	if transport != nil {
+		httpClient = &http.Client{Transport: transport}
+		apiOpts = append(apiOpts, gigachat.WithHTTPClient(httpClient))
+	}
+
+	authManager, err := auth.NewManager(ctx, cfg.AuthURL, clientID, clientSecret, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init auth manager: %w", err)
+	}
+
+	gcc, err := client.NewClient(*cfg, authManager, st.sessions, st.messages, st.files, apiOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run the authentication handler in a separate goroutine
+	wg := gcc.AuthManager.Run(ctx)
+	go func() {
+		defer close(gcc.AuthManager.ErrorChan)
+		wg.Wait()
+	}()
+	return gcc, nil
+}
+
+// stores are the storages of the chat history
+type stores struct {
+	sessions storage.SessionStore
+	messages storage.MessageStore
+	files    storage.FileStore
+	close    func() error // closes the database
+}
+
+// openStores makes the history stores, kept in memory only if noHistory is set
+func openStores(cfg *config.Config, noHistory bool) (*stores, error) {
+	if noHistory {
+		memoryMessages := storage.NewMemoryMessages()
+		return &stores{
+			sessions: storage.NewMemorySessions(memoryMessages),
+			messages: memoryMessages,
+			files:    storage.NewMemoryFiles(),
+			close:    func() error { return nil },
+		}, nil
+	}
+
+	// Initialize database and apply pending schema migrations
+	db, err := openDatabase(cfg.DatabaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	st := &stores{close: db.Close}
+	if st.sessions, st.messages, err = storage.NewStores(db); err == nil {
+		st.files, err = storage.NewFileStore(db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// openDatabase opens the database selected by the DSN and applies pending schema migrations
+func openDatabase(dsn string) (*sqlx.DB, error) {
+	db, err := storage.Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	slog.Debug("database opened", "driver", db.DriverName())
+
+	applied, err := storage.Migrate(db, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	for _, m := range applied {
+		slog.Info("applied schema migration", "version", m.Version, "name", m.Name)
+	}
+	return db, nil
+}
+
+// parseArgs parses flags mixed with positional arguments and returns the positional ones
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
 // promptUser prompts the user with a given message and returns the input
 func promptUser(prompt string) (string, error) {
 	r := bufio.NewReader(os.Stdin)
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/client"
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/mcp"
+	"github.com/gennadis/gigachatui/internal/tools"
+)
+
+const (
+	// defaultSearchLimit is the number of messages search_history returns unless asked otherwise
+	defaultSearchLimit = 10
+	// maxSessionNameLength limits the names of the sessions started by ask_gigachat
+	maxSessionNameLength = 40
+)
+
+// runMCPServe serves GigaChat and the chat history as MCP tools over stdio until stdin is closed
+func runMCPServe(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("mcp-serve", flag.ExitOnError)
+	noHistory := fs.Bool("no-history", false, "keep sessions in memory only, without writing them to the database")
+	recording := addRecordFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: mcp-serve [flags]")
+		fs.PrintDefaults()
+	}
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	transport, err := recording.transport()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	st, err := openStores(cfg, *noHistory)
+	if err != nil {
+		return err
+	}
+	defer st.close()
+
+	gcc, err := newClient(ctx, cfg, transport, st)
+	if err != nil {
+		return fmt.Errorf("failed to create GigaChat API client: %w", err)
+	}
+
+	registry, err := tools.NewRegistry(askTool(gcc), searchHistoryTool(gcc), listSessionsTool(gcc))
+	if err != nil {
+		return err
+	}
+
+	// Stdout carries the protocol, the logs go to stderr
+	slog.Info("serving MCP over stdio")
+	srv := mcp.NewServer(mcp.Implementation{Name: "gigachatui", Version: "1.0.0"}, registry)
+	if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to serve MCP: %w", err)
+	}
+	return nil
+}
+
+// askArgs are the arguments of ask_gigachat
+type askArgs struct {
+	Question  string `json:"question"`
+	SessionID string `json:"session_id"`
+}
+
+// askResult is the result of ask_gigachat
+type askResult struct {
+	SessionID string   `json:"session_id"`
+	Answer    string   `json:"answer"`
+	Images    []string `json:"images,omitempty"` // paths of the generated images
+}
+
+// askTool asks GigaChat a question in a new or an existing session
+func askTool(gcc *client.Client) tools.Tool {
+	return tools.NewFunc("ask_gigachat",
+		"Asks GigaChat a question and returns its answer. Pass the session_id of a previous answer to continue that conversation",
+		`{
+			"type": "object",
+			"properties": {
+				"question": {"type": "string", "description": "The question or the task for GigaChat"},
+				"session_id": {"type": "string", "description": "Session to continue, a new session is started if empty"}
+			},
+			"required": ["question"]
+		}`,
+		func(ctx context.Context, args askArgs) (any, error) {
+			if args.Question == "" {
+				return nil, errors.New("question is required")
+			}
+			sessionID := args.SessionID
+			if sessionID == "" {
+				session := chat.NewSession(sessionName(args.Question))
+				if err := gcc.SessionStorage.Write(*session); err != nil {
+					return nil, fmt.Errorf("failed to write session to storage: %w", err)
+				}
+				sessionID = session.ID
+			}
+
+			answer, err := gcc.RequestCompletion(ctx, sessionID, args.Question, func(client.Event) error { return nil })
+			if err != nil {
+				return nil, err
+			}
+			return askResult{SessionID: sessionID, Answer: answer.Content, Images: answer.Images}, nil
+		})
+}
+
+// sessionName names the session after the question
+func sessionName(question string) string {
+	name := []rune(question)
+	if len(name) > maxSessionNameLength {
+		return string(name[:maxSessionNameLength]) + "…"
+	}
+	return string(name)
+}
+
+// searchHistoryArgs are the arguments of search_history
+type searchHistoryArgs struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+// historyMessage is a message found by search_history
+type historyMessage struct {
+	SessionID string    `json:"session_id"`
+	Role      chat.Role `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// searchHistoryTool searches the messages of all sessions
+func searchHistoryTool(gcc *client.Client) tools.Tool {
+	return tools.NewFunc("search_history",
+		"Searches the messages of all chat sessions, best matches first",
+		`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "Words to search for"},
+				"limit": {"type": "integer", "description": "Maximum number of messages, 10 by default"}
+			},
+			"required": ["query"]
+		}`,
+		func(_ context.Context, args searchHistoryArgs) (any, error) {
+			if args.Limit <= 0 {
+				args.Limit = defaultSearchLimit
+			}
+			found, err := gcc.MessageStorage.Search(args.Query, args.Limit)
+			if err != nil {
+				return nil, err
+			}
+			messages := make([]historyMessage, 0, len(found))
+			for _, m := range found {
+				messages = append(messages, historyMessage{SessionID: m.SessionID, Role: m.Role, Content: m.Content, Timestamp: m.Timestamp})
+			}
+			return map[string]any{"messages": messages}, nil
+		})
+}
+
+// historySession is a session listed by list_sessions
+type historySession struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// listSessionsTool lists the chat sessions
+func listSessionsTool(gcc *client.Client) tools.Tool {
+	return tools.NewFunc("list_sessions",
+		"Lists the chat sessions, newest first",
+		`{"type": "object", "properties": {}}`,
+		func(context.Context, struct{}) (any, error) {
+			found, err := gcc.SessionStorage.Read()
+			if err != nil {
+				return nil, err
+			}
+			sessions := make([]historySession, 0, len(found))
+			for _, s := range found {
+				sessions = append(sessions, historySession{ID: s.ID, Name: s.Name, Timestamp: s.Timestamp})
+			}
+			return map[string]any{"sessions": sessions}, nil
+		})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+
+	"github.com/gennadis/gigachatui/internal/httprecord"
+)
+
+// recordFlags are the flags recording or replaying the API interactions
+type recordFlags struct {
+	record *string
+	replay *string
+}
+
+// addRecordFlags registers the record and replay flags
+func addRecordFlags(fs *flag.FlagSet) recordFlags {
+	return recordFlags{
+		record: fs.String("record", "", "record every API request and response to the directory, secrets redacted"),
+		replay: fs.String("replay", "", "serve the API responses recorded in the directory instead of hitting the network"),
+	}
+}
+
+// transport returns the HTTP transport of the selected mode, nil sends requests as usual
+func (f recordFlags) transport() (http.RoundTripper, error) {
+	switch {
+	case *f.record != "" && *f.replay != "":
+		return nil, errors.New("--record and --replay cannot be used together")
+	case *f.record != "":
+		return httprecord.NewRecorder(*f.record, nil)
+	case *f.replay != "":
+		return httprecord.NewReplayer(*f.replay)
+	}
+	return nil, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/server"
+)
+
+const (
+	defaultServeAddr       = "127.0.0.1:8080"
+	serveShutdownTimeout   = time.Second * 10
+	serveReadHeaderTimeout = time.Second * 10
+)
+
+// runServe serves the OpenAI compatible API and the history API backed by GigaChat until interrupted
+func runServe(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", defaultServeAddr, "address to listen on")
+	noHistory := fs.Bool("no-history", false, "keep sessions in memory only, without writing them to the database")
+	recording := addRecordFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: serve [flags]")
+		fs.PrintDefaults()
+	}
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	transport, err := recording.transport()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Make sessions and messages stores served by the history API
+	st, err := openStores(cfg, *noHistory)
+	if err != nil {
+		return err
+	}
+	defer st.close()
+
+	gcc, err := newClient(ctx, cfg, transport, st)
+	if err != nil {
+		return fmt.Errorf("failed to create GigaChat API client: %w", err)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(gcc, cfg.ServeToken),
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("serving local API", "addr", *addr, "auth", cfg.ServeToken != "")
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/client"
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/mcp"
+	"github.com/gennadis/gigachatui/internal/tools"
+)
+
+// registerTools registers the built-in tools enabled in the config and the tools of the MCP servers,
+// their calls ask the user for approval unless approved in the config
+func registerTools(ctx context.Context, gcc *client.Client, cfg *config.Config) error {
+	builtin, err := tools.Builtin(tools.BuiltinOptions{AllowedHosts: cfg.FetchAllowedHosts})
+	if err != nil {
+		return fmt.Errorf("failed to create built-in tools: %w", err)
+	}
+
+	approval := &tools.Approval{
+		Tools:    cfg.ToolsApproved,
+		Commands: cfg.ShellApprovedCommands,
+		Ask:      askApproval,
+	}
+	for _, name := range cfg.Tools {
+		t, ok := builtin[name]
+		if !ok {
+			return fmt.Errorf("unknown built-in tool %s", name)
+		}
+		if err := gcc.Tools.Register(approval.Wrap(t)); err != nil {
+			return fmt.Errorf("failed to register tool: %w", err)
+		}
+	}
+
+	if cfg.MCPConfig == "" {
+		return nil
+	}
+	mcpConfig, err := mcp.LoadConfig(cfg.MCPConfig)
+	if err != nil {
+		return err
+	}
+	for name, server := range mcpConfig.Servers {
+		// The servers run as long as the chat, they exit when their stdin closes with it
+		c, err := mcp.Start(ctx, server)
+		if err != nil {
+			return fmt.Errorf("failed to start MCP server %s: %w", name, err)
+		}
+		serverTools, err := mcp.Tools(ctx, c, name)
+		if err != nil {
+			return fmt.Errorf("failed to list tools of MCP server %s: %w", name, err)
+		}
+		for _, t := range serverTools {
+			if err := gcc.Tools.Register(approval.Wrap(t)); err != nil {
+				return fmt.Errorf("failed to register tool: %w", err)
+			}
+		}
+		slog.Info("connected to MCP server", "name", name, "tools", len(serverTools))
+	}
+	return nil
+}
+
+// askApproval asks the user whether the assistant may make the tool call printed before
+func askApproval(_ context.Context, name string, _ json.RawMessage) (bool, error) {
+	answer, err := promptUser(fmt.Sprintf("Allow %s? [y/N] ", name))
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains([]string{"y", "yes"}, strings.ToLower(answer)), nil
+}
@@ -0,0 +1,152 @@
+package gigachat
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultOAuthURL is the URL of the GigaChat authentication API
+	DefaultOAuthURL = "https://ngw.devices.sberbank.ru:9443/api/v2/oauth"
+
+	// ScopePersonal is the API scope of individuals
+	ScopePersonal = "GIGACHAT_API_PERS"
+	// ScopeB2B is the API scope of businesses with prepaid packages
+	ScopeB2B = "GIGACHAT_API_B2B"
+	// ScopeCorp is the API scope of businesses with postpaid usage
+	ScopeCorp = "GIGACHAT_API_CORP"
+
+	contentTypeURLEncoded = "application/x-www-form-urlencoded"
+
+	// tokenRefreshMargin is how long before the expiration the cached token is refreshed
+	tokenRefreshMargin = time.Minute
+)
+
+// TokenSource provides access tokens for API requests
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is a function used as a TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token implements TokenSource
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken returns a TokenSource always providing the same token
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// Token is an access token issued by the authentication API
+type Token struct {
+	AccessToken string `json:"access_token"`
+	ExpiresAt   uint64 `json:"expires_at"` // unix time in milliseconds
+}
+
+// Expires returns the expiration time of the token
+func (t Token) Expires() time.Time {
+	return time.UnixMilli(int64(t.ExpiresAt))
+}
+
+// authErrorResponse is an error response of the authentication API
+type authErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// OAuth is a TokenSource exchanging the client credentials for access tokens.
+// Tokens are cached and refreshed shortly before they expire
+type OAuth struct {
+	ClientID     string
+	ClientSecret string
+	Scope        string       // ScopePersonal if empty
+	URL          string       // DefaultOAuthURL if empty
+	HTTPClient   *http.Client // http.DefaultClient if nil
+
+	mu    sync.Mutex
+	token Token
+}
+
+// Token implements TokenSource
+func (o *OAuth) Token(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.token.AccessToken != "" && time.Until(o.token.Expires()) > tokenRefreshMargin {
+		return o.token.AccessToken, nil
+	}
+	t, err := o.RequestToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	o.token = *t
+	return t.AccessToken, nil
+}
+
+// RequestToken requests a new access token from the authentication API, bypassing the cache
+func (o *OAuth) RequestToken(ctx context.Context) (*Token, error) {
+	scope := o.Scope
+	if scope == "" {
+		scope = ScopePersonal
+	}
+	authURL := o.URL
+	if authURL == "" {
+		authURL = DefaultOAuthURL
+	}
+	httpClient := o.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	payload := strings.NewReader(url.Values{"scope": {scope}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authURL, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authentication request: %w", err)
+	}
+
+	authSecret := base64.StdEncoding.EncodeToString([]byte(o.ClientID + ":" + o.ClientSecret))
+	req.Header.Add("Content-Type", contentTypeURLEncoded)
+	req.Header.Add("Accept", contentTypeJSON)
+	req.Header.Add("RqUID", uuid.NewString())
+	req.Header.Add("Authorization", "Basic "+authSecret)
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send authentication request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authentication response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var authErr authErrorResponse
+		if err := json.Unmarshal(body, &authErr); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal authentication error response: %w", err)
+		}
+		return nil, fmt.Errorf("failed API request: status code %d, error code %d, message %s", res.StatusCode, authErr.Code, authErr.Message)
+	}
+
+	var t Token
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal authentication response: %w", err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,229 @@
+package gigachat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the GigaChat API
+	DefaultBaseURL = "https://gigachat.devices.sberbank.ru/api/v1"
+
+	contentTypeJSON = "application/json"
+
+	completionsEndpoint = "/chat/completions"
+	modelsEndpoint      = "/models"
+	tokensCountEndpoint = "/tokens/count"
+	embeddingsEndpoint  = "/embeddings"
+)
+
+// Client is a GigaChat API client, safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	tokens     TokenSource
+	timeouts   Timeouts
+}
+
+// Option configures the Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for API requests.
+// The GigaChat API certificate is issued by the Russian Trusted Root CA,
+// so the client must trust it unless the requests go through a proxy
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBaseURL sets the base URL of the API, DefaultBaseURL is used otherwise
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTokenSource sets the source of the access tokens authorizing API requests
+func WithTokenSource(tokens TokenSource) Option {
+	return func(c *Client) {
+		c.tokens = tokens
+	}
+}
+
+// NewClient creates a new Client. A token source is required, see WithTokenSource
+func NewClient(opts ...Option) (*Client, error) {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+		timeouts:   DefaultTimeouts(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.tokens == nil {
+		return nil, errors.New("token source is required")
+	}
+	c.httpClient = withConnectTimeout(c.httpClient, c.timeouts.Connect)
+	return c, nil
+}
+
+// Completion sends the request to the chat completions API and returns the complete response.
+// Streaming is turned off regardless of the request options
+func (c *Client) Completion(ctx context.Context, request *Request) (*Response, error) {
+	req := *request
+	req.Stream = false
+
+	var resp Response
+	if err := c.doJSON(ctx, http.MethodPost, completionsEndpoint, &req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get chat completion: %w", err)
+	}
+	return &resp, nil
+}
+
+// CompletionStream sends the request to the chat completions API and returns the stream of the response chunks.
+// Streaming is turned on regardless of the request options. The stream must be closed
+func (c *Client) CompletionStream(ctx context.Context, request *Request) (*Stream, error) {
+	req := *request
+	req.Stream = true
+
+	resp, err := c.do(ctx, http.MethodPost, completionsEndpoint, &req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat completion: %w", err)
+	}
+	return newStream(resp.Body.(*watchedBody)), nil
+}
+
+// Models returns the models available to the client
+func (c *Client) Models(ctx context.Context) (*ModelsResponse, error) {
+	var resp ModelsResponse
+	if err := c.doJSON(ctx, http.MethodGet, modelsEndpoint, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get models: %w", err)
+	}
+	return &resp, nil
+}
+
+// CountTokens returns the number of tokens in every input text for the model
+func (c *Client) CountTokens(ctx context.Context, request *TokensCountRequest) ([]TokensCount, error) {
+	var resp []TokensCount
+	if err := c.doJSON(ctx, http.MethodPost, tokensCountEndpoint, request, &resp); err != nil {
+		return nil, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return resp, nil
+}
+
+// Embeddings returns vector representations of the input texts
+func (c *Client) Embeddings(ctx context.Context, request *EmbeddingsRequest) (*EmbeddingsResponse, error) {
+	var resp EmbeddingsResponse
+	if err := c.doJSON(ctx, http.MethodPost, embeddingsEndpoint, request, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get embeddings: %w", err)
+	}
+	return &resp, nil
+}
+
+// doJSON sends the request with the JSON body and decodes the JSON response into out
+func (c *Client) doJSON(ctx context.Context, method, endpoint string, body, out any) error {
+	resp, err := c.do(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	resp.Body.(*watchedBody).progressOnRead()
+	return decodeJSON(resp.Body, out)
+}
+
+// decodeJSON decodes the JSON response body into out
+func decodeJSON(body io.Reader, out any) error {
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to unmarshal API response: %w", err)
+	}
+	return nil
+}
+
+// do sends the request with the JSON body, a nil body sends no body at all
+func (c *Client) do(ctx context.Context, method, endpoint string, body any) (*http.Response, error) {
+	var (
+		reqBody     io.Reader
+		contentType string
+	)
+	if body != nil {
+		reqBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(reqBytes)
+		contentType = contentTypeJSON
+	}
+	return c.send(ctx, method, endpoint, reqBody, contentType)
+}
+
+// send sends the authorized request to the API endpoint and checks the response status.
+// The request is limited by the client timeouts until the body of the returned response is closed
+func (c *Client) send(ctx context.Context, method, endpoint string, body io.Reader, contentType string) (*http.Response, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	ctx, wd := c.watch(ctx)
+	// Streamed bodies, like file uploads, may take longer to send than the first token timeout,
+	// so their countdown starts once they are sent. JSON bodies are sent at once
+	switch body.(type) {
+	case nil, *bytes.Reader:
+		wd.start()
+	default:
+		body = &sentBody{body: body, wd: wd}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint, body)
+	if err != nil {
+		wd.stop()
+		return nil, fmt.Errorf("failed to build API request: %w", err)
+	}
+	req.Header.Set("Accept", contentTypeJSON)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		err = wd.err(err)
+		wd.stop()
+		return nil, fmt.Errorf("failed to send API request: %w", err)
+	}
+	resp.Body = &watchedBody{body: resp.Body, wd: wd}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// APIError is returned when the API responds with a non-OK status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("failed to process API response: status code %d, body: %s", e.StatusCode, e.Body)
+}
+
+// checkStatus returns APIError for non-OK responses
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read API error response body: %w", err)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package gigachat_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+)
+
+// question returns a request with a single user message
+func question(content string) *gigachat.Request {
+	return &gigachat.Request{
+		Model:    gigachat.ModelGigaChat,
+		Messages: []gigachat.Message{{Role: gigachat.RoleUser, Content: content}},
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	srv.ReplyText("Paris is the capital of France")
+
+	resp, err := srv.Client().Completion(context.Background(), question("What is the capital of France?"))
+	if err != nil {
+		t.Fatalf("failed to get completion: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "Paris is the capital of France" {
+		t.Errorf("unexpected choices: %+v", resp.Choices)
+	}
+	if resp.Choices[0].FinishReason != "stop" || resp.Usage.TotalTokens == 0 {
+		t.Errorf("unexpected finish reason %q or usage %+v", resp.Choices[0].FinishReason, resp.Usage)
+	}
+
+	requests := srv.CompletionRequests()
+	if len(requests) != 1 || requests[0].Stream {
+		t.Errorf("unexpected completion requests: %+v", requests)
+	}
+}
+
+func TestCompletionStream(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	srv.Reply(gigachattest.Reply{Chunks: []string{"Hel", "lo", "!"}, FinishReason: "length"})
+
+	stream, err := srv.Client().CompletionStream(context.Background(), question("Hi"))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	defer stream.Close()
+
+	var (
+		text   strings.Builder
+		finish string
+		usage  gigachat.Usage
+	)
+	for stream.Next() {
+		chunk := stream.Current()
+		text.WriteString(chunk.Choices[0].Delta.Content)
+		if chunk.Choices[0].FinishReason != "" {
+			finish = chunk.Choices[0].FinishReason
+			usage = chunk.Usage
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if text.String() != "Hello!" || finish != "length" || usage.TotalTokens == 0 {
+		t.Errorf("got text %q, finish reason %q, usage %+v", text.String(), finish, usage)
+	}
+	if requests := srv.CompletionRequests(); len(requests) != 1 || !requests[0].Stream {
+		t.Errorf("unexpected completion requests: %+v", requests)
+	}
+
+	// A stream cut before the done event is not a complete answer
+	srv.Reply(gigachattest.Reply{Chunks: []string{"Hel", "lo", "!"}, CutAfter: 2})
+	cut, err := srv.Client().CompletionStream(context.Background(), question("Hi"))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	defer cut.Close()
+	chunks := 0
+	for cut.Next() {
+		chunks++
+	}
+	if !errors.Is(cut.Err(), io.ErrUnexpectedEOF) || chunks != 2 {
+		t.Errorf("got %d chunks and error %v, want 2 chunks and unexpected EOF", chunks, cut.Err())
+	}
+}
+
+func TestModelsTokensEmbeddings(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	models, err := client.Models(ctx)
+	if err != nil || len(models.Data) != len(srv.Models) {
+		t.Errorf("got models %+v, %v", models, err)
+	}
+
+	counts, err := client.CountTokens(ctx, &gigachat.TokensCountRequest{Model: gigachat.ModelGigaChat, Input: []string{"one two three", "four"}})
+	if err != nil || len(counts) != 2 || counts[0].Tokens != 3 || counts[1].Characters != 4 {
+		t.Errorf("got token counts %+v, %v", counts, err)
+	}
+
+	embeddings, err := client.Embeddings(ctx, &gigachat.EmbeddingsRequest{Model: gigachat.ModelEmbeddings, Input: []string{"a", "b", "a"}})
+	if err != nil || len(embeddings.Data) != 3 {
+		t.Fatalf("got embeddings %+v, %v", embeddings, err)
+	}
+	if embeddings.Data[0].Embedding[0] != embeddings.Data[2].Embedding[0] || embeddings.Data[0].Embedding[0] == embeddings.Data[1].Embedding[0] {
+		t.Error("embeddings are not stable for equal inputs")
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	srv.Fail("/chat/completions", http.StatusTooManyRequests, `{"status":429,"message":"Too Many Requests"}`)
+	_, err := client.Completion(ctx, question("Hi"))
+	var apiErr *gigachat.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got error %v, want API error with status 429", err)
+	}
+
+	// The cached token is rejected once the server forgets it
+	srv.ExpireTokens()
+	if _, err := client.Models(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got error %v, want API error with status 401", err)
+	}
+
+	// Static tokens are sent as is
+	static := srv.Client(gigachat.WithTokenSource(gigachat.StaticToken("unknown")))
+	if _, err := static.Models(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got error %v, want API error with status 401", err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	client := srv.Client(gigachat.WithTimeouts(gigachat.Timeouts{
+		FirstToken: 100 * time.Millisecond,
+		Idle:       100 * time.Millisecond,
+	}))
+	ctx := context.Background()
+
+	srv.Reply(gigachattest.Reply{Content: "late", Delay: time.Second})
+	if _, err := client.CompletionStream(ctx, question("Hi")); !errors.Is(err, gigachat.ErrNoResponse) {
+		t.Errorf("got error %v, want no response", err)
+	}
+
+	srv.Reply(gigachattest.Reply{Chunks: []string{"a", "b", "c"}, StallAfter: 2})
+	stream, err := client.CompletionStream(ctx, question("Hi"))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	defer stream.Close()
+	chunks := 0
+	for stream.Next() {
+		chunks++
+	}
+	if !errors.Is(stream.Err(), gigachat.ErrStreamStalled) || chunks != 2 {
+		t.Errorf("got %d chunks and error %v, want 2 chunks and stalled stream", chunks, stream.Err())
+	}
+
+	// Slow streams are fine as long as the chunks keep coming
+	srv.Reply(gigachattest.Reply{Chunks: []string{"a", "b", "c", "d"}, ChunkDelay: 50 * time.Millisecond})
+	resp, err := client.CompletionStream(ctx, question("Hi"))
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	defer resp.Close()
+	for resp.Next() {
+	}
+	if err := resp.Err(); err != nil {
+		t.Errorf("slow stream failed: %v", err)
+	}
+
+	// Slow uploads are fine too, the countdown starts once the file is sent
+	file, err := client.UploadFile(ctx, "notes.txt", &slowReader{content: "abcd", delay: 50 * time.Millisecond}, gigachat.FilePurposeGeneral)
+	if err != nil || file.Bytes != 4 {
+		t.Errorf("got file %+v and error %v for slow upload", file, err)
+	}
+	failing := io.MultiReader(strings.NewReader("ab"), iotest.ErrReader(errors.New("disk error")))
+	if _, err := client.UploadFile(ctx, "notes.txt", failing, gigachat.FilePurposeGeneral); err == nil || !strings.Contains(err.Error(), "disk error") {
+		t.Errorf("got error %v for failed read, want disk error", err)
+	}
+}
+
+// slowReader reads the content a byte at a time with the delay before each byte
+type slowReader struct {
+	content string
+	delay   time.Duration
+}
+
+// Read implements io.Reader
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.content == "" {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p[:1], r.content)
+	r.content = r.content[n:]
+	return n, nil
+}
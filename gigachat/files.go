@@ -0,0 +1,125 @@
+package gigachat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+const (
+	filesEndpoint = "/files"
+
+	// FilePurposeGeneral is the purpose of files attached to chat messages
+	FilePurposeGeneral = "general"
+)
+
+// File describes a file uploaded to the API storage
+type File struct {
+	ID           string `json:"id"`
+	Object       string `json:"object"`
+	Bytes        int64  `json:"bytes"`
+	CreatedAt    int64  `json:"created_at"`
+	Filename     string `json:"filename"`
+	Purpose      string `json:"purpose"`
+	AccessPolicy string `json:"access_policy,omitempty"`
+}
+
+// FilesResponse is the list of uploaded files
+type FilesResponse struct {
+	Data []File `json:"data"`
+}
+
+// DeletedFile is the result of a file deletion
+type DeletedFile struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+}
+
+// UploadFile uploads the file content under the file name for the given purpose.
+// The content is streamed to the API as it is read, it is not buffered in memory
+func (c *Client) UploadFile(ctx context.Context, filename string, content io.Reader, purpose string) (*File, error) {
+	pr, pw := io.Pipe()
+	// Closing the reader stops the writer if the request fails before the body is sent
+	defer pr.Close()
+	w := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeUpload(w, filename, content, purpose))
+	}()
+
+	resp, err := c.send(ctx, http.MethodPost, filesEndpoint, pr, w.FormDataContentType())
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file %s: %w", filename, err)
+	}
+	defer resp.Body.Close()
+	resp.Body.(*watchedBody).progressOnRead()
+
+	var file File
+	if err := decodeJSON(resp.Body, &file); err != nil {
+		return nil, fmt.Errorf("failed to upload file %s: %w", filename, err)
+	}
+	return &file, nil
+}
+
+// writeUpload writes the multipart body of the file upload
+func writeUpload(w *multipart.Writer, filename string, content io.Reader, purpose string) error {
+	if err := w.WriteField("purpose", purpose); err != nil {
+		return fmt.Errorf("failed to write file purpose: %w", err)
+	}
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file part: %w", err)
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return fmt.Errorf("failed to write file content: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish file upload body: %w", err)
+	}
+	return nil
+}
+
+// Files returns the uploaded files
+func (c *Client) Files(ctx context.Context) ([]File, error) {
+	var resp FilesResponse
+	if err := c.doJSON(ctx, http.MethodGet, filesEndpoint, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+	return resp.Data, nil
+}
+
+// File returns the description of the uploaded file
+func (c *Client) File(ctx context.Context, id string) (*File, error) {
+	var file File
+	if err := c.doJSON(ctx, http.MethodGet, fileEndpoint(id), nil, &file); err != nil {
+		return nil, fmt.Errorf("failed to get file %s: %w", id, err)
+	}
+	return &file, nil
+}
+
+// FileContent returns the content of the file, e.g. an image generated by the model.
+// The caller must close the returned reader
+func (c *Client) FileContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, fileEndpoint(id)+"/content", nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get content of file %s: %w", id, err)
+	}
+	resp.Body.(*watchedBody).progressOnRead()
+	return resp.Body, nil
+}
+
+// DeleteFile deletes the uploaded file
+func (c *Client) DeleteFile(ctx context.Context, id string) (*DeletedFile, error) {
+	var deleted DeletedFile
+	if err := c.doJSON(ctx, http.MethodPost, fileEndpoint(id)+"/delete", nil, &deleted); err != nil {
+		return nil, fmt.Errorf("failed to delete file %s: %w", id, err)
+	}
+	return &deleted, nil
+}
+
+// fileEndpoint returns the endpoint of the file with the given id
+func fileEndpoint(id string) string {
+	return filesEndpoint + "/" + url.PathEscape(id)
+}
@@ -0,0 +1,186 @@
+// Package gigachat is a client for the GigaChat API.
+//
+// The client covers chat completions, streamed or not, models, token counting,
+// embeddings and files. It does not store or print anything, callers get plain values back.
+// More info about the API can be found here: https://developers.sber.ru/docs/ru/gigachat/api/reference/rest/gigachat-api
+package gigachat
+
+import "encoding/json"
+
+// Model is the name of a GigaChat model
+type Model string
+
+const (
+	// ModelGigaChat is the GigaChat Lite model
+	ModelGigaChat Model = "GigaChat"
+	// ModelGigaChatPro is the GigaChat Pro model
+	ModelGigaChatPro Model = "GigaChat-Pro"
+	// ModelEmbeddings is the default embeddings model
+	ModelEmbeddings Model = "Embeddings"
+)
+
+// Role is the role of a message author
+type Role string
+
+const (
+	// RoleUser is the user prompt
+	RoleUser Role = "user"
+	// RoleAssistant is the assistant response
+	RoleAssistant Role = "assistant"
+	// RoleSystem is the system prompt
+	RoleSystem Role = "system"
+	// RoleFunction is the result of a function called by the assistant
+	RoleFunction Role = "function"
+)
+
+// FinishReasonFunctionCall is the finish reason of an answer calling a function
+const FinishReasonFunctionCall = "function_call"
+
+// Message is a message of the conversation sent to or received from the chat completions API
+type Message struct {
+	Role    Role   `json:"role,omitempty"`
+	Content string `json:"content"`
+	// FunctionCall is the function the assistant calls instead of answering
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	// Name is the name of the function whose result the function message carries
+	Name string `json:"name,omitempty"`
+	// FunctionsStateID links the function call to the functions of the request
+	FunctionsStateID string `json:"functions_state_id,omitempty"`
+	// Attachments are the IDs of the uploaded files the user message refers to, see Client.UploadFile
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// FunctionCall is a call of a function by the assistant
+type FunctionCall struct {
+	Name string `json:"name"`
+	// Arguments is the JSON object of the call arguments
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Function describes a function the assistant may call
+type Function struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the function arguments
+	Parameters json.RawMessage `json:"parameters"`
+	// ReturnParameters is the JSON schema of the function result
+	ReturnParameters json.RawMessage `json:"return_parameters,omitempty"`
+}
+
+const (
+	// FunctionCallAuto lets the model decide whether to call a function
+	FunctionCallAuto = "auto"
+	// FunctionCallNone forbids function calls
+	FunctionCallNone = "none"
+)
+
+// FunctionCallName forces the call of the named function
+type FunctionCallName struct {
+	Name string `json:"name"`
+}
+
+// Request is a chat completions request.
+// Options left zero are omitted, so the API defaults apply
+type Request struct {
+	Model    Model     `json:"model"`
+	Messages []Message `json:"messages"`
+	// Functions are the functions the assistant may call
+	Functions []Function `json:"functions,omitempty"`
+	// FunctionCall is FunctionCallAuto, FunctionCallNone or FunctionCallName
+	FunctionCall any `json:"function_call,omitempty"`
+	Options
+}
+
+// Options are the generation options of a chat completions request
+type Options struct {
+	Temperature       float64 `json:"temperature,omitempty"`
+	TopP              float64 `json:"top_p,omitempty"`
+	N                 int64   `json:"n,omitempty"`
+	Stream            bool    `json:"stream"`
+	MaxTokens         int64   `json:"max_tokens,omitempty"`
+	RepetitionPenalty float64 `json:"repetition_penalty,omitempty"`
+	UpdateInterval    float64 `json:"update_interval,omitempty"`
+}
+
+// Usage is the number of tokens spent on a request
+type Usage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
+}
+
+// Choice is a choice of the chat response.
+// Streamed choices carry a Delta, complete responses carry a Message
+type Choice struct {
+	Delta        Message `json:"delta"`
+	Message      Message `json:"message"`
+	Index        int32   `json:"index"`
+	FinishReason string  `json:"finish_reason,omitempty"`
+}
+
+// Response is a complete, non-streamed chat response
+type Response struct {
+	Choices []Choice `json:"choices"`
+	Created int64    `json:"created"`
+	Model   Model    `json:"model"`
+	Object  string   `json:"object"`
+	Usage   Usage    `json:"usage"`
+}
+
+// StreamChunk is a chunk of the streamed chat response
+type StreamChunk struct {
+	Choices []Choice `json:"choices"`
+	Created int64    `json:"created"`
+	Model   Model    `json:"model"`
+	Object  string   `json:"object"`
+	Usage   Usage    `json:"usage"`
+}
+
+// ModelInfo describes a model available to the client
+type ModelInfo struct {
+	ID      Model  `json:"id"`
+	Object  string `json:"object"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ModelsResponse is the list of available models
+type ModelsResponse struct {
+	Data   []ModelInfo `json:"data"`
+	Object string      `json:"object"`
+}
+
+// TokensCountRequest is a request to count tokens of the input texts
+type TokensCountRequest struct {
+	Model Model    `json:"model"`
+	Input []string `json:"input"`
+}
+
+// TokensCount is the number of tokens and characters in an input text
+type TokensCount struct {
+	Object     string `json:"object"`
+	Tokens     int32  `json:"tokens"`
+	Characters int32  `json:"characters"`
+}
+
+// EmbeddingsRequest is a request to get vector representations of the input texts
+type EmbeddingsRequest struct {
+	Model Model    `json:"model"`
+	Input []string `json:"input"`
+}
+
+// Embedding is a vector representation of an input text
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+	Usage     struct {
+		PromptTokens int32 `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// EmbeddingsResponse is the list of embeddings in the order of the input texts
+type EmbeddingsResponse struct {
+	Data   []Embedding `json:"data"`
+	Model  Model       `json:"model"`
+	Object string      `json:"object"`
+}
@@ -0,0 +1,111 @@
+package gigachattest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+)
+
+// storedFile is a file kept by the fake files storage
+type storedFile struct {
+	gigachat.File
+	content []byte
+}
+
+// AddFile stores the file as if it was uploaded or generated by the model and returns its description
+func (s *Server) AddFile(filename, purpose string, content []byte) gigachat.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := gigachat.File{
+		ID:        fmt.Sprintf("file-%d", len(s.files)+1),
+		Object:    "file",
+		Bytes:     int64(len(content)),
+		CreatedAt: time.Now().Unix(),
+		Filename:  filename,
+		Purpose:   purpose,
+	}
+	s.files = append(s.files, storedFile{File: file, content: content})
+	return file
+}
+
+// file returns the stored file by id
+func (s *Server) file(id string) (storedFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.files, func(f storedFile) bool { return f.ID == id })
+	if i < 0 {
+		return storedFile{}, false
+	}
+	return s.files[i], true
+}
+
+// handleUploadFile stores the uploaded multipart file
+func (s *Server) handleUploadFile(w http.ResponseWriter, r *http.Request) {
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, `{"status":400,"message":"file is required"}`)
+		return
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, `{"status":400,"message":"invalid file"}`)
+		return
+	}
+	writeJSON(w, s.AddFile(header.Filename, r.FormValue("purpose"), content))
+}
+
+// handleFiles lists the stored files
+func (s *Server) handleFiles(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	resp := gigachat.FilesResponse{Data: []gigachat.File{}}
+	for _, f := range s.files {
+		resp.Data = append(resp.Data, f.File)
+	}
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+// handleFile describes the stored file
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.file(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, `{"status":404,"message":"file not found"}`)
+		return
+	}
+	writeJSON(w, f.File)
+}
+
+// handleFileContent returns the content of the stored file
+func (s *Server) handleFileContent(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.file(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, `{"status":404,"message":"file not found"}`)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(f.content))
+	w.Write(f.content)
+}
+
+// handleDeleteFile deletes the stored file
+func (s *Server) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	i := slices.IndexFunc(s.files, func(f storedFile) bool { return f.ID == id })
+	if i >= 0 {
+		s.files = slices.Delete(s.files, i, i+1)
+	}
+	s.mu.Unlock()
+
+	if i < 0 {
+		writeError(w, http.StatusNotFound, `{"status":404,"message":"file not found"}`)
+		return
+	}
+	writeJSON(w, gigachat.DeletedFile{ID: id, Deleted: true})
+}
@@ -0,0 +1,524 @@
+// Package gigachattest provides an in-process fake of the GigaChat API for offline tests.
+//
+// The fake implements the OAuth token endpoint, chat completions, streamed or not,
+// models, token counting, embeddings and files. Completions answer with scripted replies or function calls,
+// which may be delayed, stall in the middle of the stream or fail with an error status.
+package gigachattest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+)
+
+const (
+	// ClientID is the client ID accepted by the fake OAuth endpoint
+	ClientID = "test-client-id"
+	// ClientSecret is the client secret accepted by the fake OAuth endpoint
+	ClientSecret = "test-client-secret"
+
+	// OAuthPath is the path of the fake OAuth endpoint
+	OAuthPath = "/api/v2/oauth"
+	// APIPath is the path prefix of the fake API endpoints
+	APIPath = "/api/v1"
+
+	// DefaultReply is the answer given when no reply is scripted
+	DefaultReply = "Hello from the fake GigaChat"
+
+	embeddingSize = 8
+)
+
+// Reply is a scripted answer of the chat completions endpoint
+type Reply struct {
+	// Content is the answer text
+	Content string
+	// Chunks split the streamed answer, Content is streamed in words if empty
+	Chunks []string
+	// FunctionCall calls the function instead of answering, it comes with the last chunk
+	FunctionCall *gigachat.FunctionCall
+	// FinishReason is "stop", or "function_call" for a FunctionCall, if empty
+	FinishReason string
+	// Usage is estimated from the request and the answer if zero
+	Usage gigachat.Usage
+	// Delay is the pause before the response
+	Delay time.Duration
+	// ChunkDelay is the pause before every streamed chunk
+	ChunkDelay time.Duration
+	// StallAfter stops a stream without ending it after the number of chunks, until the request is canceled
+	StallAfter int
+	// CutAfter ends a stream without the done event after the number of chunks, as if the connection broke
+	CutAfter int
+	// Status responds with the error status and Body instead of an answer
+	Status int
+	// Body is the body of the error response
+	Body string
+}
+
+// Request is a request received by the fake
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is a fake GigaChat API server
+type Server struct {
+	*httptest.Server
+
+	// TokenTTL is the lifetime of issued access tokens
+	TokenTTL time.Duration
+	// Models are listed by the models endpoint
+	Models []gigachat.ModelInfo
+
+	mu       sync.Mutex
+	files    []storedFile
+	states   map[string]bool // functions_state_id values of the function calls made so far
+	replies  []Reply
+	failures map[string][]Reply
+	tokens   map[string]time.Time
+	issued   int
+	requests []Request
+}
+
+// NewServer starts a new fake server, it must be closed by the caller
+func NewServer() *Server {
+	s := &Server{
+		TokenTTL: time.Minute * 30,
+		Models: []gigachat.ModelInfo{
+			{ID: gigachat.ModelGigaChat, Object: "model", OwnedBy: "salutedevices"},
+			{ID: gigachat.ModelGigaChatPro, Object: "model", OwnedBy: "salutedevices"},
+		},
+		failures: make(map[string][]Reply),
+		tokens:   make(map[string]time.Time),
+		states:   make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+OAuthPath, s.handleOAuth)
+	mux.HandleFunc("POST "+APIPath+"/chat/completions", s.authorized(s.handleCompletions))
+	mux.HandleFunc("GET "+APIPath+"/models", s.authorized(s.handleModels))
+	mux.HandleFunc("POST "+APIPath+"/tokens/count", s.authorized(s.handleTokensCount))
+	mux.HandleFunc("POST "+APIPath+"/embeddings", s.authorized(s.handleEmbeddings))
+	mux.HandleFunc("POST "+APIPath+"/files", s.authorized(s.handleUploadFile))
+	mux.HandleFunc("GET "+APIPath+"/files", s.authorized(s.handleFiles))
+	mux.HandleFunc("GET "+APIPath+"/files/{id}", s.authorized(s.handleFile))
+	mux.HandleFunc("GET "+APIPath+"/files/{id}/content", s.authorized(s.handleFileContent))
+	mux.HandleFunc("POST "+APIPath+"/files/{id}/delete", s.authorized(s.handleDeleteFile))
+	s.Server = httptest.NewServer(s.record(mux))
+	return s
+}
+
+// BaseURL returns the base URL of the fake API
+func (s *Server) BaseURL() string {
+	return s.URL + APIPath
+}
+
+// OAuthURL returns the URL of the fake OAuth endpoint
+func (s *Server) OAuthURL() string {
+	return s.URL + OAuthPath
+}
+
+// Client returns an SDK client of the fake API authorized with the fake credentials
+func (s *Server) Client(opts ...gigachat.Option) *gigachat.Client {
+	oauth := &gigachat.OAuth{
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		URL:          s.OAuthURL(),
+		HTTPClient:   s.Server.Client(),
+	}
+	opts = append([]gigachat.Option{
+		gigachat.WithBaseURL(s.BaseURL()),
+		gigachat.WithTokenSource(oauth),
+		gigachat.WithHTTPClient(s.Server.Client()),
+	}, opts...)
+	c, err := gigachat.NewClient(opts...)
+	if err != nil {
+		panic(fmt.Sprintf("gigachattest: failed to create client: %v", err))
+	}
+	return c
+}
+
+// Reply queues answers of the chat completions endpoint, answered in order
+func (s *Server) Reply(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// ReplyText queues answers with the given texts
+func (s *Server) ReplyText(contents ...string) {
+	for _, content := range contents {
+		s.Reply(Reply{Content: content})
+	}
+}
+
+// Fail makes the next request to the path, e.g. /chat/completions or the OAuth path, fail with the status
+func (s *Server) Fail(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], Reply{Status: status, Body: body})
+}
+
+// Requests returns the requests received by the fake, optionally only those to the path
+func (s *Server) Requests(path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []Request
+	for _, r := range s.requests {
+		if path == "" || strings.TrimPrefix(r.Path, APIPath) == path || r.Path == path {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// CompletionRequests returns the decoded chat completions requests received by the fake
+func (s *Server) CompletionRequests() []gigachat.Request {
+	var requests []gigachat.Request
+	for _, r := range s.Requests("/chat/completions") {
+		var req gigachat.Request
+		if err := json.Unmarshal(r.Body, &req); err == nil {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// ExpireTokens makes all issued access tokens invalid
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.tokens)
+}
+
+// record keeps the received requests and injects the scripted failures
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		path := strings.TrimPrefix(r.URL.Path, APIPath)
+		if r.URL.Path == OAuthPath {
+			path = OAuthPath
+		}
+		var failure *Reply
+		if failures := s.failures[path]; len(failures) > 0 {
+			failure = &failures[0]
+			s.failures[path] = failures[1:]
+		}
+		s.mu.Unlock()
+
+		if failure != nil {
+			writeError(w, failure.Status, failure.Body)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized rejects requests without a valid access token
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		expires, ok := s.tokens[token]
+		s.mu.Unlock()
+
+		if !ok || time.Now().After(expires) {
+			writeError(w, http.StatusUnauthorized, `{"status":401,"message":"Unauthorized"}`)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleOAuth issues access tokens for the fake credentials
+func (s *Server) handleOAuth(w http.ResponseWriter, r *http.Request) {
+	secret := base64.StdEncoding.EncodeToString([]byte(ClientID + ":" + ClientSecret))
+	if r.Header.Get("Authorization") != "Basic "+secret {
+		writeError(w, http.StatusUnauthorized, `{"code":6,"message":"credentials doesn't match db data"}`)
+		return
+	}
+	if r.Header.Get("RqUID") == "" {
+		writeError(w, http.StatusBadRequest, `{"code":5,"message":"RqUID is required"}`)
+		return
+	}
+
+	s.mu.Lock()
+	s.issued++
+	token := fmt.Sprintf("fake-token-%d", s.issued)
+	expires := time.Now().Add(s.TokenTTL)
+	s.tokens[token] = expires
+	s.mu.Unlock()
+
+	writeJSON(w, gigachat.Token{AccessToken: token, ExpiresAt: uint64(expires.UnixMilli())})
+}
+
+// handleCompletions answers with the next scripted reply
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	var req gigachat.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, `{"status":400,"message":"invalid request"}`)
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusUnprocessableEntity, `{"status":422,"message":"messages are required"}`)
+		return
+	}
+	for _, m := range req.Messages {
+		// Like the real API, the fake ties function results to the calls by their state ID
+		if m.FunctionCall != nil && !s.knownState(m.FunctionsStateID) {
+			writeError(w, http.StatusBadRequest, `{"status":400,"message":"unknown functions_state_id of the function call"}`)
+			return
+		}
+		for _, id := range m.Attachments {
+			if _, ok := s.file(id); !ok {
+				writeError(w, http.StatusBadRequest, `{"status":400,"message":"attached file not found"}`)
+				return
+			}
+		}
+	}
+
+	reply := s.nextReply()
+	if !sleep(r, reply.Delay) {
+		return
+	}
+	if reply.Status != 0 {
+		writeError(w, reply.Status, reply.Body)
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = gigachat.ModelGigaChat
+	}
+	usage := reply.usage(req)
+	var stateID string
+	if reply.FunctionCall != nil {
+		stateID = s.newState()
+	}
+	if !req.Stream {
+		writeJSON(w, gigachat.Response{
+			Choices: []gigachat.Choice{{
+				Message: gigachat.Message{
+					Role:             gigachat.RoleAssistant,
+					Content:          reply.Content,
+					FunctionCall:     reply.FunctionCall,
+					FunctionsStateID: stateID,
+				},
+				FinishReason: reply.finishReason(),
+			}},
+			Created: time.Now().Unix(),
+			Model:   model,
+			Object:  "chat.completion",
+			Usage:   usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	chunks := reply.chunks()
+	for i, content := range chunks {
+		if reply.StallAfter > 0 && i == reply.StallAfter {
+			<-r.Context().Done()
+			return
+		}
+		if reply.CutAfter > 0 && i == reply.CutAfter {
+			return
+		}
+		if !sleep(r, reply.ChunkDelay) {
+			return
+		}
+
+		chunk := gigachat.StreamChunk{
+			Choices: []gigachat.Choice{{Delta: gigachat.Message{Content: content}}},
+			Created: time.Now().Unix(),
+			Model:   model,
+			Object:  "chat.completion",
+		}
+		if i == 0 {
+			chunk.Choices[0].Delta.Role = gigachat.RoleAssistant
+		}
+		if i == len(chunks)-1 {
+			chunk.Choices[0].Delta.FunctionCall = reply.FunctionCall
+			chunk.Choices[0].Delta.FunctionsStateID = stateID
+			chunk.Choices[0].FinishReason = reply.finishReason()
+			chunk.Usage = usage
+		}
+		writeEvent(w, chunk)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// handleModels lists the models
+func (s *Server) handleModels(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, gigachat.ModelsResponse{Data: s.Models, Object: "list"})
+}
+
+// handleTokensCount estimates the tokens as the number of words
+func (s *Server) handleTokensCount(w http.ResponseWriter, r *http.Request) {
+	var req gigachat.TokensCountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, `{"status":400,"message":"invalid request"}`)
+		return
+	}
+
+	counts := make([]gigachat.TokensCount, 0, len(req.Input))
+	for _, input := range req.Input {
+		counts = append(counts, gigachat.TokensCount{
+			Object:     "tokens",
+			Tokens:     countTokens(input),
+			Characters: int32(len([]rune(input))),
+		})
+	}
+	writeJSON(w, counts)
+}
+
+// handleEmbeddings returns stable pseudo-random vectors derived from the input texts
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req gigachat.EmbeddingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, `{"status":400,"message":"invalid request"}`)
+		return
+	}
+
+	resp := gigachat.EmbeddingsResponse{Model: req.Model, Object: "list"}
+	for i, input := range req.Input {
+		e := gigachat.Embedding{Object: "embedding", Embedding: embed(input), Index: i}
+		e.Usage.PromptTokens = countTokens(input)
+		resp.Data = append(resp.Data, e)
+	}
+	writeJSON(w, resp)
+}
+
+// newState issues the functions_state_id of a function call
+func (s *Server) newState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("functions-state-%d", len(s.states)+1)
+	s.states[id] = true
+	return id
+}
+
+// knownState reports whether the functions_state_id was issued by the fake
+func (s *Server) knownState(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[id]
+}
+
+// nextReply takes the next scripted reply or the default one
+func (s *Server) nextReply() Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.replies) == 0 {
+		return Reply{Content: DefaultReply}
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply
+}
+
+// chunks returns the streamed pieces of the answer
+func (r Reply) chunks() []string {
+	if len(r.Chunks) > 0 {
+		return r.Chunks
+	}
+	words := strings.SplitAfter(r.Content, " ")
+	if len(words) == 0 {
+		return []string{""}
+	}
+	return words
+}
+
+// finishReason returns the finish reason of the answer
+func (r Reply) finishReason() string {
+	if r.FinishReason == "" {
+		if r.FunctionCall != nil {
+			return gigachat.FinishReasonFunctionCall
+		}
+		return "stop"
+	}
+	return r.FinishReason
+}
+
+// usage returns the scripted usage or estimates it
+func (r Reply) usage(req gigachat.Request) gigachat.Usage {
+	if r.Usage != (gigachat.Usage{}) {
+		return r.Usage
+	}
+	var usage gigachat.Usage
+	for _, m := range req.Messages {
+		usage.PromptTokens += countTokens(m.Content)
+	}
+	usage.CompletionTokens = countTokens(strings.Join(r.chunks(), ""))
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// countTokens estimates the number of tokens as the number of words
+func countTokens(text string) int32 {
+	return int32(len(strings.Fields(text)))
+}
+
+// embed returns a stable vector for the text
+func embed(text string) []float64 {
+	vector := make([]float64, embeddingSize)
+	for i := range vector {
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%d:%s", i, text)
+		vector[i] = float64(h.Sum32())/float64(^uint32(0))*2 - 1
+	}
+	return vector
+}
+
+// sleep waits for the delay and reports whether the request is still alive
+func sleep(r *http.Request, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(delay):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// writeJSON writes the value as a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) //nolint:errcheck // the client sees a broken response
+}
+
+// writeError writes the error response
+func writeError(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, body) //nolint:errcheck // the client sees a broken response
+}
+
+// writeEvent writes the value as a server-sent event and flushes it
+func writeEvent(w http.ResponseWriter, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
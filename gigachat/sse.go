@@ -0,0 +1,150 @@
+package gigachat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// maxEventLineSize limits the length of a single event stream line
+const maxEventLineSize = 16 << 20
+
+// ErrEventLineTooLong is returned when an event stream line exceeds the size limit
+var ErrEventLineTooLong = errors.New("event stream line too long")
+
+// utf8BOM may start the event stream and is skipped
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Event is a server-sent event.
+// More info can be found here: https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	Type  string        // event field, "message" if the event has none
+	Data  string        // data fields joined with line feeds
+	ID    string        // last event ID seen in the stream
+	Retry time.Duration // reconnection time, zero if the stream did not set it
+}
+
+// EventReader reads server-sent events from an event stream
+type EventReader struct {
+	r       *bufio.Reader
+	started bool
+	lastID  string
+	retry   time.Duration
+}
+
+// NewEventReader creates a new EventReader reading the event stream from r
+func NewEventReader(r io.Reader) *EventReader {
+	return &EventReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event of the stream. Lines are terminated by CRLF, LF or CR,
+// comments and unknown fields are ignored and an event is dispatched by a blank line.
+// An incomplete event at the end of the stream is discarded, as the spec requires, and io.EOF is returned
+func (er *EventReader) Next() (Event, error) {
+	var (
+		eventType string
+		data      bytes.Buffer
+		hasData   bool
+	)
+	for {
+		line, err := er.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+
+		// A blank line dispatches the event, events without data are skipped
+		if len(line) == 0 {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return Event{
+				Type:  eventType,
+				Data:  string(bytes.TrimSuffix(data.Bytes(), []byte("\n"))),
+				ID:    er.lastID,
+				Retry: er.retry,
+			}, nil
+		}
+
+		// Lines starting with a colon are comments, e.g. keep-alive pings
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, found := bytes.Cut(line, []byte(":"))
+		if found {
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+		switch string(field) {
+		case "event":
+			eventType = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				er.lastID = string(value)
+			}
+		case "retry":
+			if !isDigits(value) {
+				continue
+			}
+			if ms, err := strconv.ParseUint(string(value), 10, 32); err == nil {
+				er.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// readLine reads the next line without its terminator, which is CRLF, LF or CR
+func (er *EventReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		b, err := er.r.ReadByte()
+		if err != nil {
+			// An unterminated last line cannot complete an event, so it is dropped
+			return nil, err
+		}
+
+		switch b {
+		case '\n':
+			return er.trimBOM(line), nil
+		case '\r':
+			if next, err := er.r.Peek(1); err == nil && next[0] == '\n' {
+				er.r.ReadByte() //nolint:errcheck // the byte was peeked
+			}
+			return er.trimBOM(line), nil
+		}
+
+		if len(line) >= maxEventLineSize {
+			return nil, ErrEventLineTooLong
+		}
+		line = append(line, b)
+	}
+}
+
+// trimBOM strips the byte order mark from the first line of the stream
+func (er *EventReader) trimBOM(line []byte) []byte {
+	if er.started {
+		return line
+	}
+	er.started = true
+	return bytes.TrimPrefix(line, utf8BOM)
+}
+
+// isDigits reports whether the value consists of ASCII digits only
+func isDigits(value []byte) bool {
+	for _, b := range value {
+		if b < '0' || b > '9' {
+			return false
+		}
+	}
+	return len(value) > 0
+}
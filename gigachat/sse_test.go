@@ -0,0 +1,179 @@
+package gigachat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvents reads all events of the stream
+func readEvents(t *testing.T, stream string) ([]Event, error) {
+	t.Helper()
+	er := NewEventReader(strings.NewReader(stream))
+	var events []Event
+	for {
+		event, err := er.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+func TestEventReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{
+			name:   "data lines",
+			stream: "data: first\n\ndata: second\n\n",
+			want:   []Event{{Type: "message", Data: "first"}, {Type: "message", Data: "second"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: line 1\ndata: line 2\ndata\n\n",
+			want:   []Event{{Type: "message", Data: "line 1\nline 2\n"}},
+		},
+		{
+			name:   "event type, id and retry",
+			stream: "event: update\nid: 42\nretry: 1500\ndata: {}\n\ndata: next\n\n",
+			want: []Event{
+				{Type: "update", Data: "{}", ID: "42", Retry: 1500 * time.Millisecond},
+				{Type: "message", Data: "next", ID: "42", Retry: 1500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "comments and unknown fields",
+			stream: ": ping\nfoo: bar\ndata: value\n: another ping\n\n",
+			want:   []Event{{Type: "message", Data: "value"}},
+		},
+		{
+			name:   "events without data are skipped",
+			stream: "event: empty\n\n\n\ndata: value\n\n",
+			want:   []Event{{Type: "message", Data: "value"}},
+		},
+		{
+			name:   "line terminators",
+			stream: "data: crlf\r\n\r\ndata: cr\r\rdata: lf\n\n",
+			want:   []Event{{Type: "message", Data: "crlf"}, {Type: "message", Data: "cr"}, {Type: "message", Data: "lf"}},
+		},
+		{
+			name:   "only one leading space is removed",
+			stream: "data:no space\ndata:  two spaces\n\n",
+			want:   []Event{{Type: "message", Data: "no space\n two spaces"}},
+		},
+		{
+			name:   "byte order mark",
+			stream: "\xEF\xBB\xBFdata: bom\n\n",
+			want:   []Event{{Type: "message", Data: "bom"}},
+		},
+		{
+			name:   "invalid retry and id with NULL are ignored",
+			stream: "id: 1\nretry: soon\nid: a\x00b\ndata: x\n\n",
+			want:   []Event{{Type: "message", Data: "x", ID: "1"}},
+		},
+		{
+			name:   "incomplete event at the end is discarded",
+			stream: "data: done\n\ndata: partial\n",
+			want:   []Event{{Type: "message", Data: "done"}},
+		},
+		{
+			name:   "large line",
+			stream: "data: " + strings.Repeat("x", 1<<20) + "\n\n",
+			want:   []Event{{Type: "message", Data: strings.Repeat("x", 1<<20)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readEvents(t, tt.stream)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestStream creates a Stream reading the body without timeouts
+func newTestStream(body string) *Stream {
+	_, wd := (&Client{}).watch(context.Background())
+	return newStream(&watchedBody{body: io.NopCloser(strings.NewReader(body)), wd: wd})
+}
+
+func TestStream(t *testing.T) {
+	body := ": keep-alive\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\r\n\r\n" +
+		"data: [DONE]\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"ignored\"}}]}\n\n"
+	stream := newTestStream(body)
+
+	var text strings.Builder
+	for stream.Next() {
+		text.WriteString(stream.Current().Choices[0].Delta.Content)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text.String() != "Hello" {
+		t.Errorf("got %q, want %q", text.String(), "Hello")
+	}
+}
+
+func FuzzEventReader(f *testing.F) {
+	f.Add("data: {\"choices\":[]}\n\ndata: [DONE]\n\n")
+	f.Add("event: x\nid: 1\nretry: 10\ndata: a\ndata: b\n\n")
+	f.Add(": comment\r\ndata\r\rdata:\n\n")
+	f.Add("\xEF\xBB\xBFdata: bom\n")
+	f.Add("id: \x00\nretry: -1\n\n\n")
+
+	f.Fuzz(func(t *testing.T, stream string) {
+		events, err := readEvents(t, stream)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(events) > strings.Count(stream, "\n")+strings.Count(stream, "\r") {
+			t.Fatalf("got %d events from %d lines", len(events), strings.Count(stream, "\n"))
+		}
+		for _, e := range events {
+			if e.Type == "" {
+				t.Errorf("event without type: %+v", e)
+			}
+			if strings.ContainsRune(e.ID, 0) {
+				t.Errorf("event id with NULL: %q", e.ID)
+			}
+			if e.Retry < 0 {
+				t.Errorf("negative retry: %v", e.Retry)
+			}
+		}
+	})
+}
+
+func FuzzStream(f *testing.F) {
+	f.Add("data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: [DONE]\n\n")
+	f.Add("data: {not json}\n\n")
+	f.Add("data: {\"choices\":\n\n")
+
+	f.Fuzz(func(t *testing.T, body string) {
+		stream := newTestStream(body)
+		for stream.Next() {
+			_ = stream.Current()
+		}
+		// The stream stays finished after an error or the end
+		if stream.Next() {
+			t.Fatal("stream continued after the end")
+		}
+	})
+}
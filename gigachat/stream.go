@@ -0,0 +1,79 @@
+package gigachat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// streamDataDone is the data of the event ending the completion stream
+const streamDataDone = "[DONE]"
+
+// Stream iterates over the chunks of a streamed chat response:
+//
+//	for stream.Next() {
+//		chunk := stream.Current()
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type Stream struct {
+	body    *watchedBody
+	events  *EventReader
+	current StreamChunk
+	err     error
+	done    bool
+}
+
+// newStream creates a Stream reading the response body
+func newStream(body *watchedBody) *Stream {
+	return &Stream{
+		body:   body,
+		events: NewEventReader(body),
+	}
+}
+
+// Next advances the stream to the next chunk and reports whether there is one
+func (s *Stream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+	event, err := s.events.Next()
+	if err != nil {
+		// The stream ends with the done event, the connection broke before it
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		s.err = fmt.Errorf("failed to read completion response stream: %w", err)
+		return false
+	}
+	s.body.wd.alive()
+	if event.Data == streamDataDone {
+		s.done = true
+		return false
+	}
+
+	var chunk StreamChunk
+	if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+		s.err = fmt.Errorf("failed to unmarshal completion response stream chunk: %w", err)
+		return false
+	}
+	s.current = chunk
+	return true
+}
+
+// Current returns the chunk read by the last call to Next
+func (s *Stream) Current() StreamChunk {
+	return s.current
+}
+
+// Err returns the error that stopped the stream, if any
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close closes the response body
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
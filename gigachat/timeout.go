@@ -0,0 +1,197 @@
+package gigachat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default timeouts of the API requests
+const (
+	DefaultConnectTimeout    = time.Second * 10
+	DefaultFirstTokenTimeout = time.Minute
+	DefaultIdleTimeout       = time.Second * 30
+)
+
+var (
+	// ErrNoResponse is returned when the API does not start answering within the first token timeout
+	ErrNoResponse = errors.New("no response")
+	// ErrStreamStalled is returned when the answer stops streaming for longer than the idle timeout
+	ErrStreamStalled = errors.New("stream stalled")
+)
+
+// Timeouts limit the phases of API requests rather than the whole request,
+// so answers may stream in as long as they keep coming. Zero disables a timeout
+type Timeouts struct {
+	// Connect limits establishing the connection, including the TLS handshake
+	Connect time.Duration
+	// FirstToken limits waiting for the response, the first chunk of streamed answers
+	FirstToken time.Duration
+	// Idle limits the pause between the chunks of streamed answers and reading other response bodies
+	Idle time.Duration
+}
+
+// DefaultTimeouts returns the default timeouts
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Connect:    DefaultConnectTimeout,
+		FirstToken: DefaultFirstTokenTimeout,
+		Idle:       DefaultIdleTimeout,
+	}
+}
+
+// WithTimeouts sets the timeouts of API requests, DefaultTimeouts are used otherwise.
+// The connect timeout applies to HTTP clients using an *http.Transport
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(c *Client) {
+		c.timeouts = timeouts
+	}
+}
+
+// withConnectTimeout returns a copy of the HTTP client dialing with the timeout.
+// Clients with custom transports are returned as is
+func withConnectTimeout(httpClient *http.Client, timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		return httpClient
+	}
+
+	base, ok := httpClient.Transport.(*http.Transport)
+	if httpClient.Transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return httpClient
+	}
+
+	transport := base.Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+
+	hc := *httpClient
+	hc.Transport = transport
+	return &hc
+}
+
+// watchdog cancels a request which waits for the first response or the next chunk for too long
+type watchdog struct {
+	mu         sync.Mutex
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+	timer      *time.Timer
+	firstToken time.Duration
+	idle       time.Duration
+	started    bool
+}
+
+// watch returns the request context canceled once the countdown started by start or alive runs out
+func (c *Client) watch(ctx context.Context) (context.Context, *watchdog) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return ctx, &watchdog{ctx: ctx, cancel: cancel, firstToken: c.timeouts.FirstToken, idle: c.timeouts.Idle}
+}
+
+// start starts the countdown with the first token timeout once the request is sent.
+// It does nothing if the countdown is already started
+func (w *watchdog) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.started {
+		return
+	}
+	w.started = true
+	if timeout := w.firstToken; timeout > 0 && w.ctx.Err() == nil {
+		w.timer = time.AfterFunc(timeout, func() {
+			w.cancel(fmt.Errorf("%w within %s", ErrNoResponse, timeout))
+		})
+	}
+}
+
+// alive restarts the countdown with the idle timeout after the response made progress
+func (w *watchdog) alive() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.started = true
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if timeout := w.idle; timeout > 0 && w.ctx.Err() == nil {
+		w.timer = time.AfterFunc(timeout, func() {
+			w.cancel(fmt.Errorf("%w for %s", ErrStreamStalled, timeout))
+		})
+	}
+}
+
+// stop stops the countdown and releases the request context
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.cancel(context.Canceled)
+}
+
+// err returns the timeout error if the watchdog canceled the request, otherwise err as is
+func (w *watchdog) err(err error) error {
+	cause := context.Cause(w.ctx)
+	if errors.Is(cause, ErrNoResponse) || errors.Is(cause, ErrStreamStalled) {
+		return cause
+	}
+	return err
+}
+
+// sentBody is a request body which starts the watchdog countdown once it is read to the end
+type sentBody struct {
+	body io.Reader
+	wd   *watchdog
+}
+
+// Read implements io.Reader
+func (b *sentBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.wd.start()
+	}
+	return n, err
+}
+
+// watchedBody is a response body read under the watchdog, closing it stops the watchdog
+type watchedBody struct {
+	body   io.ReadCloser
+	wd     *watchdog
+	onRead bool
+}
+
+// progressOnRead makes every read restart the idle countdown.
+// Streams restart it on every event instead
+func (b *watchedBody) progressOnRead() {
+	b.onRead = true
+	b.wd.alive()
+}
+
+// Read implements io.Reader, reads canceled by the watchdog return the timeout error
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && b.onRead {
+		b.wd.alive()
+	}
+	if err != nil {
+		err = b.wd.err(err)
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *watchedBody) Close() error {
+	b.wd.stop()
+	return b.body.Close()
+}
@@ -1,11 +1,12 @@
 module github.com/gennadis/gigachatui
 
-go 1.22.4
+go 1.23
 
 require (
 	github.com/google/uuid v1.6.0
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
 	modernc.org/sqlite v1.30.1
 )
 
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+)
+
+func TestNewManager(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+
+	m, err := NewManager(context.Background(), srv.OAuthURL(), gigachattest.ClientID, gigachattest.ClientSecret, nil)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	if m.AccessToken() == "" {
+		t.Fatal("access token is empty")
+	}
+	if m.Token.ExpiresAt == 0 {
+		t.Error("token expiration is not set")
+	}
+
+	requests := srv.Requests(gigachattest.OAuthPath)
+	if len(requests) != 1 {
+		t.Fatalf("got %d token requests, want 1", len(requests))
+	}
+	if body := string(requests[0].Body); body != "scope=GIGACHAT_API_PERS" {
+		t.Errorf("got token request body %q", body)
+	}
+}
+
+func TestNewManagerErrors(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+
+	if _, err := NewManager(context.Background(), srv.OAuthURL(), gigachattest.ClientID, "wrong", nil); err == nil {
+		t.Error("expected error for invalid credentials")
+	} else if !strings.Contains(err.Error(), "status code 401") {
+		t.Errorf("unexpected error for invalid credentials: %v", err)
+	}
+
+	srv.Fail(gigachattest.OAuthPath, http.StatusInternalServerError, `{"code":1,"message":"unavailable"}`)
+	if _, err := NewManager(context.Background(), srv.OAuthURL(), gigachattest.ClientID, gigachattest.ClientSecret, nil); err == nil {
+		t.Error("expected error for failed token request")
+	} else if !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("unexpected error for failed token request: %v", err)
+	}
+}
+
+func TestRunRotatesToken(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+
+	m, err := NewManager(context.Background(), srv.OAuthURL(), gigachattest.ClientID, gigachattest.ClientSecret, nil)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	initial := m.AccessToken()
+
+	// The token is rotated once more when the manager is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := m.Run(ctx)
+	cancel()
+	wg.Wait()
+
+	if rotated := m.AccessToken(); rotated == initial {
+		t.Errorf("token was not rotated: %s", rotated)
+	}
+	token, err := m.TokenSource().Token(context.Background())
+	if err != nil || token != m.AccessToken() {
+		t.Errorf("token source returned %q, %v", token, err)
+	}
+}
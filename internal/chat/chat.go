@@ -3,6 +3,7 @@ package chat
 import (
 	"time"
 
+	"github.com/gennadis/gigachatui/gigachat"
 	"github.com/google/uuid"
 )
 
@@ -21,10 +22,27 @@ const (
 // Message represents a message in the chat
 type Message struct {
 	ID        string    `db:"id" json:"-"`
-	Content   string    `db:"content"`
-	Role      Role      `db:"role"`
+	Content   string    `db:"content" json:"content"`
+	Role      Role      `db:"role" json:"role"`
 	SessionID string    `db:"session_id" json:"-"`
+	ParentID  string    `db:"parent_id" json:"-"` // previous message in the branch, empty for the root
+	Seq       int64     `db:"seq" json:"-"`       // position in the session, assigned by the storage
 	Timestamp time.Time `db:"timestamp" json:"-"`
+	Model     Model     `db:"model" json:"-"` // model which generated the assistant message
+	Usage     Usage     `db:"usage" json:"-"` // tokens spent on the assistant message
+	Starred   bool      `db:"starred" json:"-"`
+	// FunctionCall is the function the assistant calls instead of answering
+	FunctionCall *FunctionCall `db:"function_call" json:"function_call,omitempty"`
+	// FunctionName is the function whose result the function message carries
+	FunctionName string `db:"function_name" json:"name,omitempty"`
+	// FunctionsStateID ties the function call of the assistant message to its result, it is sent back with the call
+	FunctionsStateID string `db:"functions_state_id" json:"functions_state_id,omitempty"`
+	// Attachments are the IDs of the uploaded files sent with the user message
+	Attachments Attachments `db:"attachments" json:"attachments,omitempty"`
+	// InlinedFiles are the local files whose contents were appended to the user message
+	InlinedFiles InlinedFiles `db:"inlined_files" json:"-"`
+	// Images are the local paths of the images generated by the model for the assistant message
+	Images Images `db:"images" json:"-"`
 }
 
 // NewMessage creates a new Message
@@ -39,40 +57,49 @@ func NewMessage(content string, role Role, sessionID string) *Message {
 }
 
 // Model represents the model type for the chat
-type Model string
+type Model = gigachat.Model
 
 const (
 	// ChatModelLite represents GigaChat Lite Model
-	ChatModelLite Model = "GigaChat"
+	ChatModelLite = gigachat.ModelGigaChat
 	// ChatModelPro represents GigaChat Pro Model
-	ChatModelPro Model = "GigaChat-Pro"
+	ChatModelPro = gigachat.ModelGigaChatPro
 )
 
 // Role represents the role of a message in the chat
-type Role string
+type Role = gigachat.Role
 
 const (
 	// RoleUser represents user propmt
-	RoleUser Role = "user"
+	RoleUser = gigachat.RoleUser
 	// RoleAssistant represents assistant response
-	RoleAssistant Role = "assistant"
+	RoleAssistant = gigachat.RoleAssistant
 	// RoleSystem represents system prompt
-	RoleSystem Role = "system"
+	RoleSystem = gigachat.RoleSystem
+	// RoleFunction represents function result
+	RoleFunction = gigachat.RoleFunction
 )
 
-// Request represents a request to the chat API
-type Request struct {
-	Model    Model     `json:"model"`
-	Messages []Message `json:"messages"`
-	Options
-}
-
-// NewRequest creates a new Request with default options
-func NewRequest(messages []Message) *Request {
-	return &Request{
+// NewRequest creates a new API request for the messages with default options
+func NewRequest(messages []Message) *gigachat.Request {
+	apiMessages := make([]gigachat.Message, 0, len(messages))
+	for _, m := range messages {
+		apiMessage := gigachat.Message{
+			Role:             m.Role,
+			Content:          m.Content,
+			Name:             m.FunctionName,
+			FunctionsStateID: m.FunctionsStateID,
+			Attachments:      m.Attachments,
+		}
+		if m.FunctionCall != nil {
+			apiMessage.FunctionCall = (*gigachat.FunctionCall)(m.FunctionCall)
+		}
+		apiMessages = append(apiMessages, apiMessage)
+	}
+	return &gigachat.Request{
 		Model:    ChatModelLite,
-		Messages: messages,
-		Options: Options{
+		Messages: apiMessages,
+		Options: gigachat.Options{
 			Temperature:       defaultTemperature,
 			N:                 defaultN,
 			Stream:            defaultStream,
@@ -83,36 +110,9 @@ func NewRequest(messages []Message) *Request {
 	}
 }
 
-// Options represents the options for a chat request
-type Options struct {
-	Temperature       float64 `json:"temperature"`
-	TopP              float64 `json:"top_p"`
-	N                 int64   `json:"n"`
-	Stream            bool    `json:"stream"`
-	MaxTokens         int64   `json:"max_tokens"`
-	RepetitionPenalty float64 `json:"repetition_penalty"`
-	UpdateInterval    float64 `json:"update_interval"`
-}
-
 // Usage represents the usage details of a chat response
 type Usage struct {
-	PromptTokens     int32 `json:"prompt_tokens"`
-	CompletionTokens int32 `json:"completion_tokens"`
-	TotalTokens      int32 `json:"total_tokens"`
-}
-
-// Choice represents a choice in the chat response
-type Choice struct {
-	Delta Message `json:"delta"`
-	Index int32   `json:"index"`
-}
-
-// StreamChunk represents a chunk of the chat response stream
-type StreamChunk struct {
-	Choices []Choice `json:"choices"`
-	Created int64    `json:"created"`
-	Model   Model    `json:"model"`
-	Object  string   `json:"object"`
-	Usage   Usage    `json:"usage"`
-	Final   bool     `json:"-"`
+	PromptTokens     int32 `db:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int32 `db:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int32 `db:"total_tokens" json:"total_tokens"`
 }
@@ -0,0 +1,29 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// File represents a file uploaded to the GigaChat files storage
+type File struct {
+	ID        string    `db:"id"`
+	Filename  string    `db:"filename"`
+	Path      string    `db:"path"` // local path the file was uploaded from
+	Purpose   string    `db:"purpose"`
+	Bytes     int64     `db:"bytes"`
+	Timestamp time.Time `db:"timestamp"`
+}
+
+// Attachments are the IDs of the uploaded files attached to a message, they are stored as JSON
+type Attachments []string
+
+// Value implements the driver.Valuer interface
+func (a Attachments) Value() (driver.Value, error) {
+	return listValue(a)
+}
+
+// Scan implements the sql.Scanner interface
+func (a *Attachments) Scan(src any) error {
+	return scanList(src, (*[]string)(a))
+}
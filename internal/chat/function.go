@@ -0,0 +1,39 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// FunctionCall represents a function call made by the assistant, it is stored as JSON
+type FunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Value implements the driver.Valuer interface
+func (f FunctionCall) Value() (driver.Value, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal function call: %w", err)
+	}
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (f *FunctionCall) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("unsupported function call type %T", src)
+	}
+	if err := json.Unmarshal(b, f); err != nil {
+		return fmt.Errorf("failed to unmarshal function call: %w", err)
+	}
+	return nil
+}
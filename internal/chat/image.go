@@ -0,0 +1,16 @@
+package chat
+
+import "database/sql/driver"
+
+// Images are the local paths of the images saved from a message, they are stored as JSON
+type Images []string
+
+// Value implements the driver.Valuer interface
+func (i Images) Value() (driver.Value, error) {
+	return listValue(i)
+}
+
+// Scan implements the sql.Scanner interface
+func (i *Images) Scan(src any) error {
+	return scanList(src, (*[]string)(i))
+}
@@ -0,0 +1,16 @@
+package chat
+
+import "database/sql/driver"
+
+// InlinedFiles are the paths of the local files whose contents were inlined into a message, they are stored as JSON
+type InlinedFiles []string
+
+// Value implements the driver.Valuer interface
+func (f InlinedFiles) Value() (driver.Value, error) {
+	return listValue(f)
+}
+
+// Scan implements the sql.Scanner interface
+func (f *InlinedFiles) Scan(src any) error {
+	return scanList(src, (*[]string)(f))
+}
@@ -0,0 +1,41 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// listValue stores the list as a JSON array, or as an empty string if it is empty
+func listValue(list []string) (driver.Value, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal list: %w", err)
+	}
+	return string(b), nil
+}
+
+// scanList reads the list stored by listValue
+func scanList(src any, list *[]string) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	case nil:
+	default:
+		return fmt.Errorf("unsupported list type %T", src)
+	}
+	if len(b) == 0 {
+		*list = nil
+		return nil
+	}
+	if err := json.Unmarshal(b, list); err != nil {
+		return fmt.Errorf("failed to unmarshal list: %w", err)
+	}
+	return nil
+}
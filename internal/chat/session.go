@@ -10,6 +10,7 @@ import (
 type Session struct {
 	ID        string    `db:"id"`
 	Name      string    `db:"name"`
+	HeadID    string    `db:"head_id"` // last message of the active branch
 	Timestamp time.Time `db:"timestamp"`
 }
 
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// Branch returns the active branch of the session, from the first message to the head
+func (c *Client) Branch(sessionID string) ([]chat.Message, error) {
+	session, err := c.SessionStorage.Get(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session from storage: %w", err)
+	}
+	if session.HeadID == "" {
+		return nil, nil
+	}
+	return c.MessageStorage.ReadBranch(session.HeadID)
+}
+
+// Fork starts a new branch in the session after the given message.
+// The next question continues from that message, the previous branch is kept
+func (c *Client) Fork(sessionID, messageID string) error {
+	message, err := c.sessionMessage(sessionID, messageID)
+	if err != nil {
+		return err
+	}
+	if err := c.SessionStorage.SetHead(sessionID, message.ID); err != nil {
+		return fmt.Errorf("failed to move session head: %w", err)
+	}
+	return nil
+}
+
+// ForkSession copies the branch ending at the given message into a new session
+func (c *Client) ForkSession(sessionID, messageID, name string) (*chat.Session, error) {
+	message, err := c.sessionMessage(sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := c.MessageStorage.ReadBranch(message.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branch from storage: %w", err)
+	}
+
+	session := chat.NewSession(name)
+	if err := c.SessionStorage.Write(*session); err != nil {
+		return nil, fmt.Errorf("failed to write session to storage: %w", err)
+	}
+
+	parentID := ""
+	for _, m := range branch {
+		forked := chat.NewMessage(m.Content, m.Role, session.ID)
+		forked.ParentID = parentID
+		forked.Timestamp = m.Timestamp
+		forked.Model = m.Model
+		forked.Usage = m.Usage
+		forked.FunctionCall = m.FunctionCall
+		forked.FunctionName = m.FunctionName
+		forked.FunctionsStateID = m.FunctionsStateID
+		forked.Attachments = m.Attachments
+		forked.InlinedFiles = m.InlinedFiles
+		forked.Images = m.Images
+		if err := c.MessageStorage.Write(*forked); err != nil {
+			return nil, fmt.Errorf("failed to write forked message to storage: %w", err)
+		}
+		parentID = forked.ID
+	}
+
+	if err := c.SessionStorage.SetHead(session.ID, parentID); err != nil {
+		return nil, fmt.Errorf("failed to move session head: %w", err)
+	}
+	session.HeadID = parentID
+	return session, nil
+}
+
+// sessionMessage returns the message of the session by id
+func (c *Client) sessionMessage(sessionID, messageID string) (*chat.Message, error) {
+	messages, err := c.MessageStorage.ReadBySessionID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session messages from storage: %w", err)
+	}
+	for i := range messages {
+		if messages[i].ID == messageID {
+			return &messages[i], nil
+		}
+	}
+	return nil, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+}
+
+// EditMessage stores an edited version of an earlier user message next to the original one,
+// keeping its attachments and inlined files, and requests a new answer to it, passing its events to handle.
+// The original message and its answers are kept as an alternative
+func (c *Client) EditMessage(ctx context.Context, sessionID, messageID, content string, handle EventHandler) error {
+	original, err := c.sessionMessage(sessionID, messageID)
+	if err != nil {
+		return err
+	}
+	if original.Role != chat.RoleUser {
+		return fmt.Errorf("message %s is not a user message", messageID)
+	}
+
+	edited, err := c.storeUserMessage(sessionID, original.ParentID, Question{Content: content, Attachments: original.Attachments, InlinedFiles: original.InlinedFiles})
+	if err != nil {
+		return fmt.Errorf("failed to write edited message to storage: %w", err)
+	}
+	_, err = c.completeBranch(ctx, sessionID, edited.ID, handle)
+	return err
+}
+
+// Regenerate requests a new version of the last assistant answer in the active branch,
+// passing its events to handle. The previous answer is kept as an alternative
+func (c *Client) Regenerate(ctx context.Context, sessionID string, handle EventHandler) error {
+	session, err := c.SessionStorage.Get(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to read session from storage: %w", err)
+	}
+	head, err := c.sessionMessage(sessionID, session.HeadID)
+	if err != nil {
+		return err
+	}
+
+	// Answer the question again if the head is the answer to it,
+	// otherwise the head is a question left without an answer
+	questionID := head.ID
+	if head.Role == chat.RoleAssistant {
+		questionID = head.ParentID
+	}
+	_, err = c.completeBranch(ctx, sessionID, questionID, handle)
+	return err
+}
+
+// Alternatives returns all versions of the message, i.e. the messages sharing its parent, in write order
+func (c *Client) Alternatives(sessionID, messageID string) ([]chat.Message, error) {
+	messages, err := c.MessageStorage.ReadBySessionID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session messages from storage: %w", err)
+	}
+	i := slices.IndexFunc(messages, func(m chat.Message) bool { return m.ID == messageID })
+	if i < 0 {
+		return nil, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+	return children(messages, messages[i].ParentID), nil
+}
+
+// Switch makes the branch going through the message active.
+// The head moves down to the latest continuation of the message
+func (c *Client) Switch(sessionID, messageID string) error {
+	messages, err := c.MessageStorage.ReadBySessionID(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to read session messages from storage: %w", err)
+	}
+	if !slices.ContainsFunc(messages, func(m chat.Message) bool { return m.ID == messageID }) {
+		return fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	}
+
+	headID := messageID
+	for next := children(messages, headID); len(next) > 0; next = children(messages, headID) {
+		headID = next[len(next)-1].ID
+	}
+	if err := c.SessionStorage.SetHead(sessionID, headID); err != nil {
+		return fmt.Errorf("failed to move session head: %w", err)
+	}
+	return nil
+}
+
+// children returns the messages replying to parentID, in write order
+func children(messages []chat.Message, parentID string) []chat.Message {
+	var replies []chat.Message
+	for _, m := range messages {
+		if m.ParentID == parentID {
+			replies = append(replies, m)
+		}
+	}
+	return replies
+}
@@ -1,209 +1,306 @@
 package client
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log/slog"
 	"strings"
-	"time"
 
+	"github.com/gennadis/gigachatui/gigachat"
 	"github.com/gennadis/gigachatui/internal/auth"
 	"github.com/gennadis/gigachatui/internal/chat"
 	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/tools"
 	"github.com/gennadis/gigachatui/storage"
 )
 
-const (
-	streamDataPrefix    = "data: "
-	streamDataDone      = "data: [DONE]"
-	contentTypeJSON     = "application/json"
-	completionsEndpoint = "/chat/completions"
-)
+// maxFunctionCalls limits the function calls the assistant makes in a row before answering
+const maxFunctionCalls = 10
 
 // Client represents a client for interacting with the GigaChat API
+// which keeps the conversations in the storage
 type Client struct {
-	Config             *config.Config
-	AuthManager        *auth.Manager
-	SessionStorage     *storage.Sessions
-	MessageStorage     *storage.Messages
-	StreamResponseChan chan chat.StreamChunk
-	ErrorChan          chan error
-	httpClient         *http.Client
+	Config         *config.Config
+	AuthManager    *auth.Manager
+	SessionStorage storage.SessionStore
+	MessageStorage storage.MessageStore
+	FileStorage    storage.FileStore // files uploaded to the API, see UploadFile
+	API            *gigachat.Client
+	Tools          *tools.Registry // functions the assistant may call, none by default
 }
 
-// NewClient initializes a new Client instance
-func NewClient(cfg config.Config, authManager auth.Manager, sessionStorage storage.Sessions, messagesStorage storage.Messages) (*Client, error) {
+// NewClient initializes a new Client instance, the API options override the ones made from the config
+func NewClient(cfg config.Config, authManager *auth.Manager, sessionStorage storage.SessionStore, messagesStorage storage.MessageStore, fileStorage storage.FileStore, apiOpts ...gigachat.Option) (*Client, error) {
+	opts := []gigachat.Option{
+		gigachat.WithBaseURL(cfg.BaseURL),
+		gigachat.WithTokenSource(authManager.TokenSource()),
+		gigachat.WithTimeouts(cfg.Timeouts),
+	}
+	api, err := gigachat.NewClient(append(opts, apiOpts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+
 	return &Client{
-		Config:             &cfg,
-		AuthManager:        &authManager,
-		SessionStorage:     &sessionStorage,
-		MessageStorage:     &messagesStorage,
-		StreamResponseChan: make(chan chat.StreamChunk),
-		ErrorChan:          make(chan error),
-		httpClient:         &http.Client{Timeout: time.Second * 10},
+		Config:         &cfg,
+		AuthManager:    authManager,
+		SessionStorage: sessionStorage,
+		MessageStorage: messagesStorage,
+		FileStorage:    fileStorage,
+		API:            api,
+		Tools:          &tools.Registry{},
 	}, nil
 }
 
-// RequestCompletion sends a question to the chat API and processes the response
-func (c *Client) RequestCompletion(ctx context.Context, sessionID, question string) error {
-	// Store the user's message in the message storage
-	if err := c.storeUserMessage(sessionID, question); err != nil {
-		return fmt.Errorf("failed to write user message to storage: %w", err)
-	}
+// Question is a user message sent to the chat API
+type Question struct {
+	Content string
+	// Attachments are the IDs of the uploaded files sent with the question, see UploadFile
+	Attachments []string
+	// InlinedFiles are the local files whose contents the content includes, see inline.Expand
+	InlinedFiles []string
+}
 
-	// Read all messages for the given session from storage
-	// This is necessary to provide context to the chat assistant
-	sessionMessages, err := c.MessageStorage.ReadBySessionID(sessionID)
+// RequestCompletion sends a question to the chat API, passes the events of the streamed answer
+// to handle and returns the stored answer. The question continues the active branch of the session
+func (c *Client) RequestCompletion(ctx context.Context, sessionID, question string, handle EventHandler) (*chat.Message, error) {
+	return c.Ask(ctx, sessionID, Question{Content: question}, handle)
+}
+
+// Ask sends the question with its attachments to the chat API, passes the events of the streamed answer
+// to handle and returns the stored answer. The question continues the active branch of the session
+func (c *Client) Ask(ctx context.Context, sessionID string, question Question, handle EventHandler) (*chat.Message, error) {
+	session, err := c.SessionStorage.Get(sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to read session messages from storage: %w", err)
+		return nil, fmt.Errorf("failed to read session from storage: %w", err)
 	}
 
-	// Create a request with the session messages to send to the GigaChat API
-	request := chat.NewRequest(sessionMessages)
-	resp, err := c.sendCompletionRequest(ctx, request)
+	// Store the user's message in the message storage
+	userMessage, err := c.storeUserMessage(sessionID, session.HeadID, question)
 	if err != nil {
-		return fmt.Errorf("failed to get chat completion: %w", err)
+		return nil, fmt.Errorf("failed to write user message to storage: %w", err)
 	}
 
-	// Process the response from the chat API asynchronously
-	go c.processResponseStream(resp)
+	return c.completeBranch(ctx, sessionID, userMessage.ID, handle)
+}
 
-	// Collect the response from the stream and store it
-	if err := c.collectResponse(sessionID); err != nil {
-		return fmt.Errorf("failed to collect completions API response: %w", err)
-	}
+// RequestCompletionTo sends a question to the chat API, writes the streamed answer to w
+// and returns the stored answer. The question continues the active branch of the session
+func (c *Client) RequestCompletionTo(ctx context.Context, w io.Writer, sessionID, question string) (*chat.Message, error) {
+	return c.RequestCompletion(ctx, sessionID, question, WriteDeltas(w))
+}
 
-	return nil
+// completeBranch requests the assistant's answer to the branch ending at headID,
+// passes its events to handle and stores it as the new head of the session.
+// Functions called by the assistant are run and their results are sent back until it answers
+func (c *Client) completeBranch(ctx context.Context, sessionID, headID string, handle EventHandler) (*chat.Message, error) {
+	for calls := 0; ; calls++ {
+		assistantMessage, err := c.requestAnswer(ctx, sessionID, headID, handle)
+		if err != nil {
+			return nil, err
+		}
+		if assistantMessage.FunctionCall == nil {
+			if err := handle(Event{Type: EventDone, Message: assistantMessage}); err != nil {
+				return nil, err
+			}
+			return assistantMessage, nil
+		}
+		if calls == maxFunctionCalls {
+			return nil, notify(handle, fmt.Errorf("assistant made more than %d function calls in a row", maxFunctionCalls))
+		}
+
+		result, err := c.callFunction(ctx, assistantMessage, handle)
+		if err != nil {
+			return nil, err
+		}
+		headID = result.ID
+	}
 }
 
-// sendCompletionRequest sends a request to the chat completions API
-func (c *Client) sendCompletionRequest(ctx context.Context, request *chat.Request) (*http.Response, error) {
-	// Marshal the request into JSON
-	reqBytes, err := json.Marshal(request)
+// requestAnswer requests the next assistant message to the branch ending at headID,
+// passes its events to handle and stores it as the new head of the session
+func (c *Client) requestAnswer(ctx context.Context, sessionID, headID string, handle EventHandler) (*chat.Message, error) {
+	// Read the messages of the branch from storage
+	// This is necessary to provide context to the chat assistant
+	sessionMessages, err := c.MessageStorage.ReadBranch(headID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+		return nil, fmt.Errorf("failed to read session messages from storage: %w", err)
 	}
 
-	// Build the request
-	completionsPath := c.Config.BaseURL + completionsEndpoint
-	req, err := http.NewRequestWithContext(ctx, "POST", completionsPath, bytes.NewBuffer(reqBytes))
+	// Create a request with the session messages to send to the GigaChat API
+	request := chat.NewRequest(sessionMessages)
+	if c.Tools.Len() > 0 {
+		request.Functions = c.Tools.Functions()
+		request.FunctionCall = gigachat.FunctionCallAuto
+	}
+	stream, err := c.API.CompletionStream(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build completion API request: %w", err)
+		return nil, notify(handle, fmt.Errorf("failed to start completions response stream: %w", err))
 	}
+	defer stream.Close()
 
-	// Set necessary headers
-	authHeader := fmt.Sprintf("Bearer %s", c.AuthManager.Token.AccessToken)
-	req.Header.Set("Content-Type", contentTypeJSON)
-	req.Header.Set("Accept", contentTypeJSON)
-	req.Header.Set("Authorization", authHeader)
-
-	// Send the request
-	resp, err := c.httpClient.Do(req)
+	// Collect the response from the stream and store it
+	assistantMessage, err := c.collectResponse(ctx, stream, handle, sessionID, headID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send completion request: %w", err)
+		return nil, fmt.Errorf("failed to collect completions API response: %w", err)
 	}
 
-	// Handle non-200 responses
-	if err := handleNonOKStatus(resp); err != nil {
+	return assistantMessage, nil
+}
+
+// callFunction runs the function called by the assistant message and stores its result as a reply to the call.
+// A failed function is not an error, the model gets the error as the result and may recover from it
+func (c *Client) callFunction(ctx context.Context, call *chat.Message, handle EventHandler) (*chat.Message, error) {
+	if err := handle(Event{Type: EventFunctionCall, Message: call}); err != nil {
 		return nil, err
 	}
-	return resp, nil
-}
 
-// processResponseStream processes the response from the chat completions API
-func (c *Client) processResponseStream(r *http.Response) {
-	defer r.Body.Close()
-	sc := bufio.NewScanner(r.Body)
-	for sc.Scan() {
-		ln := sc.Text()
-		if ln == streamDataDone {
-			// Send a final chunk to indicate the end of the stream
-			c.StreamResponseChan <- chat.StreamChunk{Final: true}
-			return
-		}
+	name := call.FunctionCall.Name
+	content, err := c.Tools.Call(ctx, name, call.FunctionCall.Arguments)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, notify(handle, fmt.Errorf("failed to call function %s: %w", name, ctxErr))
+	}
+	if err != nil {
+		slog.Debug("function call failed",
+			slog.String("name", name),
+			slog.String("error", err.Error()),
+		)
+	}
 
-		if strings.HasPrefix(ln, streamDataPrefix) || ln == "\n" {
-			// Extract the JSON string from the line
-			jsonStr := strings.TrimPrefix(ln, streamDataPrefix)
-			var respChunk chat.StreamChunk
-			// Unmarshal the JSON string into a StreamChunk
-			if err := json.Unmarshal([]byte(jsonStr), &respChunk); err != nil {
-				c.ErrorChan <- fmt.Errorf("failed to unmarshal completion response stream chunk: %w", err)
-				return
-			}
-			// Send the chunk to the StreamRespChan
-			c.StreamResponseChan <- respChunk
-		}
+	result := chat.NewMessage(content, chat.RoleFunction, call.SessionID)
+	result.ParentID = call.ID
+	result.FunctionName = name
+	if err := c.appendMessage(result); err != nil {
+		return nil, notify(handle, fmt.Errorf("failed to write function result message to storage: %w", err))
 	}
-	// Handle any errors that occurred during scanning
-	if err := sc.Err(); err != nil {
-		c.ErrorChan <- fmt.Errorf("failed to scan completion response stream chunk: %w", err)
+	if err := handle(Event{Type: EventFunctionResult, Message: result}); err != nil {
+		return nil, err
 	}
+	return result, nil
 }
 
-func (c *Client) collectResponse(sessionID string) error {
+// collectResponse passes the streamed answer to handle as events and stores it as a reply to parentID.
+// Image tags are left out of the deltas, the generated images are downloaded once the answer is streamed.
+// EventDone is left to the caller, the answer may be a function call
+func (c *Client) collectResponse(ctx context.Context, stream *gigachat.Stream, handle EventHandler, sessionID, parentID string) (*chat.Message, error) {
 	// Buffer to build the assistant's response text incrementally
 	var assistantRespTxt strings.Builder
+	var (
+		images   imageScanner
+		imageIDs []string
+	)
+	// The answer is stored as a reply to parentID once the stream is over
+	assistantMessage := chat.NewMessage("", chat.RoleAssistant, sessionID)
+	assistantMessage.ParentID = parentID
+
+	for stream.Next() {
+		chunk := stream.Current()
 
-	for {
-		select {
-		// Handle the streaming response from the completions API
-		case chunk := <-c.StreamResponseChan:
-			// If the chunk is marked as final, write the complete response to storage
-			if chunk.Final {
-				if err := c.storeAssistantMessage(sessionID, assistantRespTxt.String()); err != nil {
-					return fmt.Errorf("failed to write assistant message to storage: %w", err)
+		// Ensure there are choices available in the chunk
+		if len(chunk.Choices) == 0 {
+			return nil, notify(handle, errors.New("no choices found in completions API response"))
+		}
+
+		// Keep the model, the usage comes with the last chunk
+		if chunk.Model != "" {
+			assistantMessage.Model = chunk.Model
+		}
+
+		// Append the chunk content to the response text
+		choice := chunk.Choices[0]
+		if choice.Delta.Content != "" {
+			assistantRespTxt.WriteString(choice.Delta.Content)
+			delta, ids := images.scan(choice.Delta.Content)
+			imageIDs = append(imageIDs, ids...)
+			if delta != "" {
+				if err := handle(Event{Type: EventDelta, Delta: delta}); err != nil {
+					return nil, err
 				}
-				return nil
 			}
-
-			// Ensure there are choices available in the chunk
-			if len(chunk.Choices) == 0 {
-				return fmt.Errorf("no choices found in completions API response")
+		}
+		if choice.Delta.FunctionCall != nil {
+			assistantMessage.FunctionCall = (*chat.FunctionCall)(choice.Delta.FunctionCall)
+		}
+		if choice.Delta.FunctionsStateID != "" {
+			assistantMessage.FunctionsStateID = choice.Delta.FunctionsStateID
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			assistantMessage.Usage = chat.Usage(chunk.Usage)
+			if err := handle(Event{Type: EventUsage, Usage: assistantMessage.Usage}); err != nil {
+				return nil, err
 			}
+		}
+		if choice.FinishReason != "" {
+			if err := handle(Event{Type: EventFinish, FinishReason: choice.FinishReason}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, notify(handle, fmt.Errorf("failed to process completions response stream: %w", err))
+	}
+	if delta := images.flush(); delta != "" {
+		if err := handle(Event{Type: EventDelta, Delta: delta}); err != nil {
+			return nil, err
+		}
+	}
 
-			// Append the chunk content to the response text
-			content := chunk.Choices[0].Delta.Content
-			assistantRespTxt.WriteString(content)
-			fmt.Print(content)
-
-		// Handle errors that may occur during the streaming response processing
-		case err := <-c.ErrorChan:
-			return fmt.Errorf("failed to process completions response stream: %w", err)
+	// The answer keeps the image tags, the saved images are linked from it
+	for _, id := range imageIDs {
+		path, err := c.downloadImage(ctx, id)
+		if err != nil {
+			// The answer is still worth keeping
+			slog.Error("failed to download generated image", "id", id, "error", err)
+			continue
+		}
+		assistantMessage.Images = append(assistantMessage.Images, path)
+		if err := handle(Event{Type: EventImage, Image: path}); err != nil {
+			return nil, err
 		}
 	}
+
+	// Write the complete response to storage once the stream is over
+	assistantMessage.Content = assistantRespTxt.String()
+	if err := c.storeAssistantMessage(assistantMessage); err != nil {
+		return nil, notify(handle, fmt.Errorf("failed to write assistant message to storage: %w", err))
+	}
+	return assistantMessage, nil
 }
 
-// storeUserMessage stores the user message in the message storage
-func (c *Client) storeUserMessage(sessionID, question string) error {
-	userMessage := chat.NewMessage(question, chat.RoleUser, sessionID)
-	if err := c.MessageStorage.Write(*userMessage); err != nil {
-		return fmt.Errorf("failed to write user message to storage: %w", err)
+// notify passes the error to handle as an EventError and returns it
+func notify(handle EventHandler, err error) error {
+	if handlerErr := handle(Event{Type: EventError, Err: err}); handlerErr != nil {
+		return handlerErr
 	}
-	return nil
+	return err
 }
 
-// storeAssistantMessage stores the assistant's response message in the message storage
-func (c *Client) storeAssistantMessage(sessionID, response string) error {
-	assistantMessage := chat.NewMessage(response, chat.RoleAssistant, sessionID)
-	if err := c.MessageStorage.Write(*assistantMessage); err != nil {
+// storeUserMessage stores the user message as a reply to parentID and moves the session head to it
+func (c *Client) storeUserMessage(sessionID, parentID string, question Question) (*chat.Message, error) {
+	userMessage := chat.NewMessage(question.Content, chat.RoleUser, sessionID)
+	userMessage.ParentID = parentID
+	userMessage.Attachments = question.Attachments
+	userMessage.InlinedFiles = question.InlinedFiles
+	if err := c.appendMessage(userMessage); err != nil {
+		return nil, fmt.Errorf("failed to write user message to storage: %w", err)
+	}
+	return userMessage, nil
+}
+
+// storeAssistantMessage stores the assistant's response message and moves the session head to it
+func (c *Client) storeAssistantMessage(assistantMessage *chat.Message) error {
+	if err := c.appendMessage(assistantMessage); err != nil {
 		return fmt.Errorf("failed to write assistant response message to storage: %w", err)
 	}
 	return nil
 }
 
-// handleNonOKStatus handles non-OK response status
-func handleNonOKStatus(resp *http.Response) error {
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read completion stream response body: %w", err)
-		}
-		return fmt.Errorf("failed to process API response with body: %s: %w", string(bodyBytes), err)
+// appendMessage writes the message and makes it the head of its session
+func (c *Client) appendMessage(message *chat.Message) error {
+	if err := c.MessageStorage.Write(*message); err != nil {
+		return err
 	}
-	return nil
+	return c.SessionStorage.SetHead(message.SessionID, message.ID)
 }
@@ -0,0 +1,530 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+	"github.com/gennadis/gigachatui/internal/auth"
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/internal/tools"
+	"github.com/gennadis/gigachatui/storage"
+	"github.com/gennadis/gigachatui/storage/storagetest"
+)
+
+// newTestClient creates a client of the fake server with a new session
+func newTestClient(t *testing.T, srv *gigachattest.Server, st storagetest.Stores) (*Client, *chat.Session) {
+	t.Helper()
+	authManager, err := auth.NewManager(context.Background(), srv.OAuthURL(), gigachattest.ClientID, gigachattest.ClientSecret, nil)
+	if err != nil {
+		t.Fatalf("failed to create auth manager: %v", err)
+	}
+	cfg := config.Config{
+		BaseURL:   srv.BaseURL(),
+		Timeouts:  gigachat.Timeouts{FirstToken: time.Second, Idle: 200 * time.Millisecond},
+		ImagesDir: t.TempDir(),
+	}
+	gcc, err := NewClient(cfg, authManager, st.Sessions, st.Messages, st.Files)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	session := chat.NewSession("test")
+	if err := st.Sessions.Write(*session); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+	return gcc, session
+}
+
+func TestRequestCompletion(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+			ctx := context.Background()
+
+			srv.Reply(gigachattest.Reply{Chunks: []string{"Hi", " there"}, Usage: gigachat.Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}})
+			var (
+				out    strings.Builder
+				events []EventType
+			)
+			answer, err := gcc.RequestCompletion(ctx, session.ID, "Hello", func(e Event) error {
+				events = append(events, e.Type)
+				return WriteDeltas(&out)(e)
+			})
+			if err != nil {
+				t.Fatalf("failed to request completion: %v", err)
+			}
+			if out.String() != "Hi there" || answer.Content != "Hi there" {
+				t.Errorf("got output %q and answer %q", out.String(), answer.Content)
+			}
+			wantEvents := []EventType{EventDelta, EventDelta, EventUsage, EventFinish, EventDone}
+			if strings.Join(eventNames(events), ",") != strings.Join(eventNames(wantEvents), ",") {
+				t.Errorf("got events %v, want %v", events, wantEvents)
+			}
+
+			// The second question is sent together with the conversation so far
+			srv.ReplyText("Fine")
+			if _, err := gcc.RequestCompletionTo(ctx, &out, session.ID, "How are you?"); err != nil {
+				t.Fatalf("failed to request completion: %v", err)
+			}
+			requests := srv.CompletionRequests()
+			if got := len(requests[1].Messages); got != 3 {
+				t.Errorf("second request has %d messages, want 3", got)
+			}
+
+			branch, err := gcc.Branch(session.ID)
+			if err != nil {
+				t.Fatalf("failed to read branch: %v", err)
+			}
+			var contents []string
+			for _, m := range branch {
+				contents = append(contents, string(m.Role)+":"+m.Content)
+			}
+			if got := strings.Join(contents, "|"); got != "user:Hello|assistant:Hi there|user:How are you?|assistant:Fine" {
+				t.Errorf("got branch %s", got)
+			}
+			if branch[1].Usage.TotalTokens != 3 || branch[1].Model != gigachat.ModelGigaChat {
+				t.Errorf("got usage %+v and model %q of the answer", branch[1].Usage, branch[1].Model)
+			}
+		})
+	}
+}
+
+func TestRequestCompletionErrors(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+			ctx := context.Background()
+
+			srv.Fail("/chat/completions", http.StatusInternalServerError, `{"status":500}`)
+			var errEvent error
+			_, err := gcc.RequestCompletion(ctx, session.ID, "Hello", func(e Event) error {
+				if e.Type == EventError {
+					errEvent = e.Err
+				}
+				return nil
+			})
+			var apiErr *gigachat.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || errEvent == nil {
+				t.Errorf("got error %v and error event %v", err, errEvent)
+			}
+
+			// The question is kept without an answer and regenerating answers it
+			stored, _ := gcc.MessageStorage.ReadBySessionID(session.ID)
+			if len(stored) != 1 || stored[0].Role != chat.RoleUser {
+				t.Fatalf("got messages %+v, want the question only", stored)
+			}
+			srv.ReplyText("Recovered")
+			if err := gcc.Regenerate(ctx, session.ID, WriteDeltas(&strings.Builder{})); err != nil {
+				t.Fatalf("failed to regenerate: %v", err)
+			}
+
+			srv.Reply(gigachattest.Reply{Chunks: []string{"a", "b"}, StallAfter: 1})
+			_, err = gcc.RequestCompletion(ctx, session.ID, "Stall", WriteDeltas(&strings.Builder{}))
+			if !errors.Is(err, gigachat.ErrStreamStalled) {
+				t.Errorf("got error %v, want stalled stream", err)
+			}
+
+			// A cut stream leaves the question unanswered instead of storing a truncated answer
+			srv.Reply(gigachattest.Reply{Chunks: []string{"a", "b"}, CutAfter: 1})
+			_, err = gcc.RequestCompletion(ctx, session.ID, "Cut", WriteDeltas(&strings.Builder{}))
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("got error %v, want unexpected EOF", err)
+			}
+			if branch, _ := gcc.Branch(session.ID); branch[len(branch)-1].Content != "Cut" {
+				t.Errorf("got last message %+v, want the unanswered question", branch[len(branch)-1])
+			}
+
+			if _, err := gcc.RequestCompletion(ctx, "missing", "Hello", WriteDeltas(&strings.Builder{})); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("got error %v, want not found", err)
+			}
+		})
+	}
+}
+
+func TestBranches(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+			ctx := context.Background()
+			discard := WriteDeltas(&strings.Builder{})
+
+			srv.ReplyText("First answer", "Second answer", "Edited answer")
+			answer, err := gcc.RequestCompletion(ctx, session.ID, "Question", discard)
+			if err != nil {
+				t.Fatalf("failed to request completion: %v", err)
+			}
+			if err := gcc.Regenerate(ctx, session.ID, discard); err != nil {
+				t.Fatalf("failed to regenerate: %v", err)
+			}
+			alternatives, err := gcc.Alternatives(session.ID, answer.ID)
+			if err != nil || len(alternatives) != 2 {
+				t.Fatalf("got alternatives %+v, %v", alternatives, err)
+			}
+
+			branch, _ := gcc.Branch(session.ID)
+			if err := gcc.EditMessage(ctx, session.ID, branch[0].ID, "Edited question", discard); err != nil {
+				t.Fatalf("failed to edit message: %v", err)
+			}
+			branch, _ = gcc.Branch(session.ID)
+			if len(branch) != 2 || branch[0].Content != "Edited question" || branch[1].Content != "Edited answer" {
+				t.Errorf("got branch %+v after edit", branch)
+			}
+
+			stored, _ := gcc.MessageStorage.ReadBySessionID(session.ID)
+			if len(stored) != 5 {
+				t.Errorf("got %d messages, want 5", len(stored))
+			}
+		})
+	}
+}
+
+func TestCompletionEvents(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	gcc, session := newTestClient(t, srv, storagetest.Memory())
+
+	srv.Reply(gigachattest.Reply{Chunks: []string{"a", "b", "c"}})
+	var deltas []string
+	for e := range gcc.CompletionEvents(context.Background(), session.ID, "Hi") {
+		if e.Type == EventDelta {
+			deltas = append(deltas, e.Delta)
+		}
+		if len(deltas) == 2 {
+			break
+		}
+	}
+	if strings.Join(deltas, "") != "ab" {
+		t.Errorf("got deltas %v", deltas)
+	}
+
+	var last Event
+	for e := range gcc.CompletionEvents(context.Background(), "missing", "Hi") {
+		last = e
+	}
+	if last.Type != EventError || !errors.Is(last.Err, storage.ErrNotFound) {
+		t.Errorf("got last event %+v, want not found error", last)
+	}
+}
+
+func TestFunctionCalling(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+
+			type weatherArgs struct {
+				City string `json:"city"`
+			}
+			weather := tools.NewFunc("weather", "Current weather in the city",
+				`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`,
+				func(_ context.Context, args weatherArgs) (any, error) {
+					return map[string]any{"city": args.City, "temperature": 21}, nil
+				})
+			if err := gcc.Tools.Register(weather); err != nil {
+				t.Fatalf("failed to register tool: %v", err)
+			}
+
+			srv.Reply(
+				gigachattest.Reply{FunctionCall: &gigachat.FunctionCall{Name: "weather", Arguments: []byte(`{"city":"Moscow"}`)}},
+				gigachattest.Reply{FunctionCall: &gigachat.FunctionCall{Name: "missing"}},
+				gigachattest.Reply{Content: "It is 21 degrees in Moscow"},
+			)
+			var events []EventType
+			answer, err := gcc.RequestCompletion(context.Background(), session.ID, "Weather in Moscow?", func(e Event) error {
+				events = append(events, e.Type)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to request completion: %v", err)
+			}
+			if answer.Content != "It is 21 degrees in Moscow" {
+				t.Errorf("got answer %q", answer.Content)
+			}
+			wantEvents := []EventType{
+				EventUsage, EventFinish, EventFunctionCall, EventFunctionResult,
+				EventUsage, EventFinish, EventFunctionCall, EventFunctionResult,
+				EventDelta, EventDelta, EventDelta, EventDelta, EventDelta, EventDelta, EventUsage, EventFinish, EventDone,
+			}
+			if strings.Join(eventNames(events), ",") != strings.Join(eventNames(wantEvents), ",") {
+				t.Errorf("got events %v, want %v", events, wantEvents)
+			}
+
+			// Every request describes the tools and carries the calls made so far
+			requests := srv.CompletionRequests()
+			if len(requests) != 3 {
+				t.Fatalf("got %d requests, want 3", len(requests))
+			}
+			if len(requests[0].Functions) != 1 || requests[0].Functions[0].Name != "weather" {
+				t.Errorf("got functions %+v", requests[0].Functions)
+			}
+			last := requests[2].Messages
+			if len(last) != 5 || last[1].FunctionCall == nil || last[2].Role != chat.RoleFunction || last[2].Name != "weather" {
+				t.Errorf("got last request messages %+v", last)
+			}
+			if last[1].FunctionsStateID != "functions-state-1" || last[3].FunctionsStateID != "functions-state-2" {
+				t.Errorf("got functions state IDs %q and %q sent back", last[1].FunctionsStateID, last[3].FunctionsStateID)
+			}
+
+			// Every step is stored in the active branch
+			branch, err := gcc.Branch(session.ID)
+			if err != nil {
+				t.Fatalf("failed to read branch: %v", err)
+			}
+			if len(branch) != 6 {
+				t.Fatalf("got %d messages in branch, want 6", len(branch))
+			}
+			if call := branch[1].FunctionCall; call == nil || call.Name != "weather" || string(call.Arguments) != `{"city":"Moscow"}` {
+				t.Errorf("got stored function call %+v", call)
+			}
+			if branch[1].FunctionsStateID != "functions-state-1" {
+				t.Errorf("got stored functions state ID %q", branch[1].FunctionsStateID)
+			}
+			if branch[2].FunctionName != "weather" || branch[2].Content != `{"city":"Moscow","temperature":21}` {
+				t.Errorf("got stored function result %q from %q", branch[2].Content, branch[2].FunctionName)
+			}
+			if !strings.Contains(branch[4].Content, "unknown tool missing") {
+				t.Errorf("got failed function result %q", branch[4].Content)
+			}
+		})
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+			ctx := context.Background()
+
+			path := filepath.Join(t.TempDir(), "notes.txt")
+			if err := os.WriteFile(path, []byte("meeting at noon"), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			file, err := gcc.UploadFile(ctx, path)
+			if err != nil {
+				t.Fatalf("failed to upload file: %v", err)
+			}
+			if file.Filename != "notes.txt" || file.Bytes != 15 || file.Path != path {
+				t.Errorf("got uploaded file %+v", file)
+			}
+			if files, _ := gcc.FileStorage.Read(); len(files) != 1 || files[0].ID != file.ID {
+				t.Errorf("got stored files %+v", files)
+			}
+
+			srv.ReplyText("At noon")
+			question := Question{Content: "When is the meeting?", Attachments: []string{file.ID}}
+			if _, err := gcc.Ask(ctx, session.ID, question, func(Event) error { return nil }); err != nil {
+				t.Fatalf("failed to ask: %v", err)
+			}
+			requests := srv.CompletionRequests()
+			if got := requests[0].Messages[0].Attachments; len(got) != 1 || got[0] != file.ID {
+				t.Errorf("got request attachments %v", got)
+			}
+			branch, err := gcc.Branch(session.ID)
+			if err != nil {
+				t.Fatalf("failed to read branch: %v", err)
+			}
+			if got := branch[0].Attachments; len(got) != 1 || got[0] != file.ID {
+				t.Errorf("got stored attachments %v", got)
+			}
+			if len(branch[1].Attachments) != 0 {
+				t.Errorf("got answer attachments %v", branch[1].Attachments)
+			}
+
+			if err := gcc.DeleteFile(ctx, file.ID); err != nil {
+				t.Fatalf("failed to delete file: %v", err)
+			}
+			if files, _ := gcc.FileStorage.Read(); len(files) != 0 {
+				t.Errorf("got stored files %+v after delete", files)
+			}
+			if err := gcc.DeleteFile(ctx, file.ID); err == nil {
+				t.Error("deleted missing file")
+			}
+
+			// Questions attaching unknown files are rejected by the API
+			srv.ReplyText("unused")
+			question.Attachments = []string{"missing"}
+			if _, err := gcc.Ask(ctx, session.ID, question, func(Event) error { return nil }); err == nil {
+				t.Error("asked with missing attachment")
+			}
+		})
+	}
+}
+
+func TestInlinedFiles(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+
+			srv.ReplyText("At noon")
+			content := "When is the meeting?\n\n```md agenda.md\nMeeting at noon\n```"
+			question := Question{Content: content, InlinedFiles: []string{"agenda.md", "notes/todo.txt"}}
+			if _, err := gcc.Ask(context.Background(), session.ID, question, func(Event) error { return nil }); err != nil {
+				t.Fatalf("failed to ask: %v", err)
+			}
+
+			// The inlined files are already in the content, their list is kept for the history only
+			requests := srv.Requests("/chat/completions")
+			if len(requests) != 1 || strings.Contains(string(requests[0].Body), "inlined_files") || strings.Contains(string(requests[0].Body), "todo.txt") {
+				t.Errorf("got request %s, want no inlined files", requests[0].Body)
+			}
+			if got := srv.CompletionRequests()[0].Messages[0]; got.Content != content || len(got.Attachments) != 0 {
+				t.Errorf("got request message %+v", got)
+			}
+
+			branch, err := gcc.Branch(session.ID)
+			if err != nil {
+				t.Fatalf("failed to read branch: %v", err)
+			}
+			if got := branch[0].InlinedFiles; strings.Join(got, ",") != "agenda.md,notes/todo.txt" {
+				t.Errorf("got stored inlined files %v", got)
+			}
+			if len(branch[1].InlinedFiles) != 0 {
+				t.Errorf("got answer inlined files %v", branch[1].InlinedFiles)
+			}
+
+			// The edited question keeps the files inlined in the original one
+			srv.ReplyText("At one")
+			if err := gcc.EditMessage(context.Background(), session.ID, branch[0].ID, content+"\nIs it still on?", func(Event) error { return nil }); err != nil {
+				t.Fatalf("failed to edit message: %v", err)
+			}
+			edited, err := gcc.Branch(session.ID)
+			if err != nil {
+				t.Fatalf("failed to read edited branch: %v", err)
+			}
+			if edited[0].ID == branch[0].ID || strings.Join(edited[0].InlinedFiles, ",") != "agenda.md,notes/todo.txt" {
+				t.Errorf("got edited question %+v", edited[0])
+			}
+		})
+	}
+}
+
+func TestImageScanner(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		text   string
+		ids    []string
+	}{
+		{name: "no tags", chunks: []string{"a < b", " and c > d"}, text: "a < b and c > d"},
+		{name: "whole tag", chunks: []string{`Here <img src="file-1" fuse="true"/> it is`}, text: "Here  it is", ids: []string{"file-1"}},
+		{name: "split tag", chunks: []string{"Here <i", `mg src='file-2`, `'>`, " done"}, text: "Here  done", ids: []string{"file-2"}},
+		{name: "other tags", chunks: []string{"<b>bold</b> <image> <img"}, text: "<b>bold</b> <image> <img"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				s    imageScanner
+				text strings.Builder
+				ids  []string
+			)
+			for _, chunk := range tt.chunks {
+				delta, found := s.scan(chunk)
+				text.WriteString(delta)
+				ids = append(ids, found...)
+			}
+			text.WriteString(s.flush())
+			if text.String() != tt.text {
+				t.Errorf("got text %q, want %q", text.String(), tt.text)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("got ids %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestGeneratedImages(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			srv := gigachattest.NewServer()
+			defer srv.Close()
+			gcc, session := newTestClient(t, srv, open(t))
+
+			png := []byte("\x89PNG\r\n\x1a\nimage")
+			image := srv.AddFile("cat.png", "assistant", png)
+			srv.Reply(gigachattest.Reply{Chunks: []string{"A cat: <img s", `rc="` + image.ID + `" fuse="true"/>`, " and <img src=\"missing\"/>"}})
+
+			var (
+				deltas strings.Builder
+				images []string
+			)
+			answer, err := gcc.RequestCompletion(context.Background(), session.ID, "Draw a cat", func(e Event) error {
+				switch e.Type {
+				case EventDelta:
+					deltas.WriteString(e.Delta)
+				case EventImage:
+					images = append(images, e.Image)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to request completion: %v", err)
+			}
+			if deltas.String() != "A cat:  and " {
+				t.Errorf("got deltas %q", deltas.String())
+			}
+			want := filepath.Join(gcc.Config.ImagesDir, image.ID+".png")
+			if len(images) != 1 || images[0] != want {
+				t.Fatalf("got images %v, want %s", images, want)
+			}
+			if saved, err := os.ReadFile(want); err != nil || string(saved) != string(png) {
+				t.Errorf("got saved image %q, %v", saved, err)
+			}
+
+			// The stored answer keeps the tags and links the saved images
+			branch, err := gcc.Branch(session.ID)
+			if err != nil {
+				t.Fatalf("failed to read branch: %v", err)
+			}
+			if stored := branch[len(branch)-1]; stored.ID != answer.ID || !strings.Contains(stored.Content, "<img") || len(stored.Images) != 1 || stored.Images[0] != want {
+				t.Errorf("got stored answer %q with images %v", stored.Content, stored.Images)
+			}
+		})
+	}
+}
+
+func TestDownloadImage(t *testing.T) {
+	srv := gigachattest.NewServer()
+	defer srv.Close()
+	gcc, _ := newTestClient(t, srv, storagetest.Memory())
+
+	large := srv.AddFile("large.png", "assistant", make([]byte, maxImageSize+1))
+	for _, id := range []string{"", ".", "..", "../cat", "a/b", large.ID} {
+		if path, err := gcc.downloadImage(context.Background(), id); err == nil {
+			t.Errorf("downloaded image %q to %s", id, path)
+		}
+	}
+	if files, _ := os.ReadDir(gcc.Config.ImagesDir); len(files) != 0 {
+		t.Errorf("got %d saved images, want none", len(files))
+	}
+}
+
+// eventNames converts the event types to strings
+func eventNames(types []EventType) []string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, string(t))
+	}
+	return names
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// EventType is the type of a completion event
+type EventType string
+
+const (
+	// EventDelta carries the next piece of the answer text
+	EventDelta EventType = "delta"
+	// EventUsage carries the tokens spent on the answer, it comes with the last chunk
+	EventUsage EventType = "usage"
+	// EventFinish carries the reason the model stopped generating
+	EventFinish EventType = "finish"
+	// EventFunctionCall carries the stored assistant message calling a function, before the function runs
+	EventFunctionCall EventType = "function_call"
+	// EventFunctionResult carries the stored function message with the result, the assistant is asked again
+	EventFunctionResult EventType = "function_result"
+	// EventImage carries the path of an image generated by the model, saved once the answer is streamed
+	EventImage EventType = "image"
+	// EventError carries the error that stopped the completion
+	EventError EventType = "error"
+	// EventDone carries the stored answer, it is the last event of a successful completion
+	EventDone EventType = "done"
+)
+
+// Event is an event of a streamed completion, only the field of its type is set
+type Event struct {
+	Type         EventType
+	Delta        string
+	Usage        chat.Usage
+	FinishReason string
+	Message      *chat.Message
+	Image        string
+	Err          error
+}
+
+// EventHandler handles completion events, returning an error stops the completion
+type EventHandler func(Event) error
+
+// errStopped stops the completion when the iterator consumer breaks out of the loop
+var errStopped = errors.New("completion events consumer stopped")
+
+// WriteDeltas returns the handler writing the answer text to w as it is streamed
+func WriteDeltas(w io.Writer) EventHandler {
+	return func(e Event) error {
+		if e.Type != EventDelta {
+			return nil
+		}
+		_, err := io.WriteString(w, e.Delta)
+		return err
+	}
+}
+
+// CompletionEvents sends a question to the chat API and yields the events of the streamed answer.
+// Every failure is yielded as an EventError, the last event is either EventDone or EventError
+func (c *Client) CompletionEvents(ctx context.Context, sessionID, question string) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		yielded := false
+		_, err := c.RequestCompletion(ctx, sessionID, question, func(e Event) error {
+			if e.Type == EventError {
+				yielded = true
+			}
+			if !yield(e) {
+				return errStopped
+			}
+			return nil
+		})
+		if err != nil && !yielded && !errors.Is(err, errStopped) {
+			yield(Event{Type: EventError, Err: err})
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/storage"
+)
+
+// UploadFile uploads the local file to the API files storage, so it can be attached to questions,
+// and keeps its ID in the file storage
+func (c *Client) UploadFile(ctx context.Context, path string) (*chat.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	uploaded, err := c.API.UploadFile(ctx, filepath.Base(path), f, gigachat.FilePurposeGeneral)
+	if err != nil {
+		return nil, err
+	}
+
+	file := chat.File{
+		ID:        uploaded.ID,
+		Filename:  uploaded.Filename,
+		Path:      path,
+		Purpose:   uploaded.Purpose,
+		Bytes:     uploaded.Bytes,
+		Timestamp: time.Now(),
+	}
+	if uploaded.CreatedAt > 0 {
+		file.Timestamp = time.Unix(uploaded.CreatedAt, 0)
+	}
+	if err := c.FileStorage.Write(file); err != nil {
+		return nil, fmt.Errorf("failed to write uploaded file to storage: %w", err)
+	}
+	return &file, nil
+}
+
+// DeleteFile deletes the file from the API files storage and forgets it
+func (c *Client) DeleteFile(ctx context.Context, id string) error {
+	if _, err := c.API.DeleteFile(ctx, id); err != nil {
+		return err
+	}
+	// Files uploaded elsewhere are not kept in the storage
+	if err := c.FileStorage.Delete(id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("failed to delete file from storage: %w", err)
+	}
+	return nil
+}
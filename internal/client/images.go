@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxImageTagLength stops holding back text which looked like the start of an image tag
+	maxImageTagLength = 1024
+	// maxImageSize limits the size of a downloaded image, it is read into memory
+	maxImageSize = 32 << 20
+)
+
+// imageTag is the tag of an image generated by the model, its source is the ID of the image file
+var imageTag = regexp.MustCompile(`(?is)^<img\s[^>]*?\bsrc\s*=\s*["']([^"']+)["'][^>]*>$`)
+
+// imageExtensions are the file extensions of the image types detected by their content
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// imageScanner removes the image tags from the streamed answer text and collects the IDs of the images.
+// A tag split between chunks is held back until it is complete
+type imageScanner struct {
+	pending string
+}
+
+// scan returns the text of the chunk without the complete image tags and the IDs of their images
+func (s *imageScanner) scan(chunk string) (string, []string) {
+	buf := s.pending + chunk
+	s.pending = ""
+
+	var (
+		text strings.Builder
+		ids  []string
+	)
+	for {
+		i := strings.IndexByte(buf, '<')
+		if i < 0 {
+			text.WriteString(buf)
+			return text.String(), ids
+		}
+		text.WriteString(buf[:i])
+		buf = buf[i:]
+
+		end := strings.IndexByte(buf, '>')
+		if end < 0 {
+			if len(buf) < maxImageTagLength && maybeImageTag(buf) {
+				s.pending = buf
+				return text.String(), ids
+			}
+			text.WriteByte('<')
+			buf = buf[1:]
+			continue
+		}
+		if m := imageTag.FindStringSubmatch(buf[:end+1]); m != nil {
+			ids = append(ids, m[1])
+			buf = buf[end+1:]
+			continue
+		}
+		text.WriteByte('<')
+		buf = buf[1:]
+	}
+}
+
+// flush returns the text held back at the end of the answer, it was not an image tag
+func (s *imageScanner) flush() string {
+	text := s.pending
+	s.pending = ""
+	return text
+}
+
+// maybeImageTag reports whether the unfinished text may become an image tag
+func maybeImageTag(text string) bool {
+	const prefix = "<img"
+	lower := strings.ToLower(text)
+	if len(lower) <= len(prefix) {
+		return strings.HasPrefix(prefix, lower)
+	}
+	return strings.HasPrefix(lower, prefix) && strings.ContainsAny(lower[len(prefix):len(prefix)+1], " \t\r\n")
+}
+
+// downloadImage saves the generated image file to the images directory and returns its path.
+// The file is named after the image ID, with the extension of its content type
+func (c *Client) downloadImage(ctx context.Context, id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid image file id %q", id)
+	}
+
+	content, err := c.API.FileContent(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	data, err := io.ReadAll(io.LimitReader(content, maxImageSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image %s: %w", id, err)
+	}
+	if len(data) > maxImageSize {
+		return "", fmt.Errorf("image %s is larger than %d bytes", id, maxImageSize)
+	}
+
+	dir := c.Config.ImagesDir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create images directory: %w", err)
+	}
+	path := filepath.Join(dir, id+imageExtensions[http.DetectContentType(data)])
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to save image %s: %w", id, err)
+	}
+	return path, nil
+}
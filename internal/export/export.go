@@ -0,0 +1,145 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// timeLayout is the timestamp layout used in human readable formats
+const timeLayout = "2006-01-02 15:04:05"
+
+// Format represents the export file format
+type Format string
+
+const (
+	// FormatMarkdown exports a session as Markdown
+	FormatMarkdown Format = "md"
+	// FormatHTML exports a session as a standalone HTML page
+	FormatHTML Format = "html"
+	// FormatJSON exports a session as JSON with all message metadata
+	FormatJSON Format = "json"
+	// FormatText exports a session as plain text
+	FormatText Format = "txt"
+)
+
+// Formats lists all supported export formats
+var Formats = []Format{FormatMarkdown, FormatHTML, FormatJSON, FormatText}
+
+// ParseFormat returns the format by its name
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %q", name)
+}
+
+// Document is an exported session with its messages
+type Document struct {
+	Session  chat.Session
+	Messages []chat.Message
+}
+
+// Usage returns the tokens spent on the whole session
+func (d *Document) Usage() chat.Usage {
+	var total chat.Usage
+	for _, m := range d.Messages {
+		total.PromptTokens += m.Usage.PromptTokens
+		total.CompletionTokens += m.Usage.CompletionTokens
+		total.TotalTokens += m.Usage.TotalTokens
+	}
+	return total
+}
+
+// Write writes the document to w in the given format
+func Write(w io.Writer, format Format, doc *Document) error {
+	switch format {
+	case FormatMarkdown:
+		return writeMarkdown(w, doc)
+	case FormatHTML:
+		return writeHTML(w, doc)
+	case FormatJSON:
+		return writeJSON(w, doc)
+	case FormatText:
+		return writeText(w, doc)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// WriteFile writes the document into its own file in the directory, named by FileName, and returns the file path
+func WriteFile(dir string, format Format, doc *Document) (string, error) {
+	path := filepath.Join(dir, FileName(doc.Session, format))
+	f, err := os.Create(path) // #nosec G304 -- the path is built from the user supplied directory
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := Write(f, format, doc); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to export session to %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close export file: %w", err)
+	}
+	return path, nil
+}
+
+// FileName returns a file name for the exported session, unique by the session id
+func FileName(session chat.Session, format Format) string {
+	id, _, _ := strings.Cut(session.ID, "-")
+	return fmt.Sprintf("%s-%s-%s.%s", session.Timestamp.Format("20060102"), slug(session.Name), id, format)
+}
+
+// slug converts the name into a file name friendly form
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r >= 'а' && r <= 'я', r == 'ё':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+	if s == "" {
+		return "session"
+	}
+	return s
+}
+
+// messageMeta returns the human readable metadata line of the message
+func messageMeta(m chat.Message) string {
+	parts := []string{formatTime(m.Timestamp)}
+	if m.Model != "" {
+		parts = append(parts, string(m.Model))
+	}
+	if m.Usage.TotalTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", m.Usage.TotalTokens))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// roleTitle returns the capitalized role name
+func roleTitle(role chat.Role) string {
+	r := string(role)
+	if r == "" {
+		return r
+	}
+	return strings.ToUpper(r[:1]) + r[1:]
+}
+
+// formatTime formats the timestamp in the local time zone
+func formatTime(t time.Time) string {
+	return t.Local().Format(timeLayout)
+}
@@ -0,0 +1,322 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// testDocument returns a session with an answer spending tokens and content to be escaped in HTML
+func testDocument() *Document {
+	created := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	session := chat.Session{ID: "3f2a9c1e-7b4d-4e8a-9c0f-1a2b3c4d5e6f", Name: "Weekly <sync> & notes", HeadID: "m3", Timestamp: created}
+	return &Document{
+		Session: session,
+		Messages: []chat.Message{
+			{ID: "m1", SessionID: session.ID, Seq: 1, Role: chat.RoleUser, Content: "Summarize <script>alert(1)</script>", Timestamp: created},
+			{ID: "m2", SessionID: session.ID, ParentID: "m1", Seq: 2, Role: chat.RoleAssistant, Content: "Done & dusted",
+				Timestamp: created.Add(time.Minute), Model: "GigaChat-Pro", Usage: chat.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+				Images: []string{"images/chart.png"}},
+			{ID: "m3", SessionID: session.ID, ParentID: "m2", Seq: 3, Role: chat.RoleAssistant, Content: "Anything else?",
+				Timestamp: created.Add(2 * time.Minute), Model: "GigaChat", Usage: chat.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}},
+		},
+	}
+}
+
+// write exports the document in the format
+func write(t *testing.T, format Format, doc *Document) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := Write(&b, format, doc); err != nil {
+		t.Fatalf("failed to export %s: %v", format, err)
+	}
+	return b.String()
+}
+
+func TestWrite(t *testing.T) {
+	doc := testDocument()
+	created := formatTime(doc.Session.Timestamp)
+	answerMeta := formatTime(doc.Messages[1].Timestamp) + " · GigaChat-Pro · 15 tokens"
+
+	tests := []struct {
+		format  Format
+		want    []string
+		wantNot []string
+	}{
+		{
+			format: FormatMarkdown,
+			want: []string{
+				"# Weekly <sync> & notes\n",
+				"- Session: `3f2a9c1e-7b4d-4e8a-9c0f-1a2b3c4d5e6f`\n",
+				"- Created: " + created + "\n",
+				"- Messages: 3\n",
+				"- Tokens: 20 (prompt 13, completion 7)\n",
+				"\n## User\n\n_" + created + "_\n\nSummarize <script>alert(1)</script>\n",
+				"\n## Assistant\n\n_" + answerMeta + "_\n\nDone & dusted\n",
+			},
+		},
+		{
+			format: FormatText,
+			want: []string{
+				"Weekly <sync> & notes\nSession: 3f2a9c1e-7b4d-4e8a-9c0f-1a2b3c4d5e6f\n",
+				"Created: " + created + "\nMessages: 3\nTokens: 20 (prompt 13, completion 7)\n",
+				textSeparator + "\nAssistant [" + answerMeta + "]\n\nDone & dusted\n",
+			},
+		},
+		{
+			format: FormatHTML,
+			want: []string{
+				"<title>Weekly &lt;sync&gt; &amp; notes</title>",
+				"<h1>Weekly &lt;sync&gt; &amp; notes</h1>",
+				"created " + created + " · 3 messages ·\n20 tokens (prompt 13, completion 7)",
+				`<div class="content">Summarize &lt;script&gt;alert(1)&lt;/script&gt;</div>`,
+				`<div class="message assistant">`,
+				`<p class="meta">` + answerMeta + "</p>",
+				"Done &amp; dusted",
+			},
+			wantNot: []string{"<script>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			got := write(t, tt.format, doc)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("export does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.wantNot {
+				if strings.Contains(got, notWant) {
+					t.Errorf("export contains %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+
+	if err := Write(&bytes.Buffer{}, "pdf", doc); err == nil {
+		t.Error("exported unknown format")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	doc := testDocument()
+	got := write(t, FormatJSON, doc)
+
+	// Content is kept as is, not escaped for HTML
+	if !strings.Contains(got, "<script>alert(1)</script>") {
+		t.Errorf("export escaped the content:\n%s", got)
+	}
+
+	var out jsonDocument
+	if err := json.Unmarshal([]byte(got), &out); err != nil {
+		t.Fatalf("failed to decode export: %v", err)
+	}
+	session := out.Session
+	if session.ID != doc.Session.ID || session.HeadID != "m3" || session.Messages != 3 || !session.Timestamp.Equal(doc.Session.Timestamp) {
+		t.Errorf("got session %+v", session)
+	}
+	if session.Usage != (chat.Usage{PromptTokens: 13, CompletionTokens: 7, TotalTokens: 20}) {
+		t.Errorf("got session usage %+v", session.Usage)
+	}
+	if len(out.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(out.Messages))
+	}
+	// Messages without usage have none in the export
+	if out.Messages[0].Usage != nil || out.Messages[0].Model != "" {
+		t.Errorf("got question %+v", out.Messages[0])
+	}
+	answer := out.Messages[1]
+	if answer.ParentID != "m1" || answer.Seq != 2 || answer.Model != "GigaChat-Pro" || answer.Usage == nil || answer.Usage.TotalTokens != 15 {
+		t.Errorf("got answer %+v", answer)
+	}
+	if len(answer.Images) != 1 || answer.Images[0] != "images/chart.png" {
+		t.Errorf("got answer images %v", answer.Images)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	first := testDocument()
+	second := testDocument()
+	second.Session.ID = "9b8c7d6e-0000-4000-8000-000000000000"
+
+	// Sessions with the same name and date get their own files
+	var paths []string
+	for _, doc := range []*Document{first, second} {
+		path, err := WriteFile(dir, FormatMarkdown, doc)
+		if err != nil {
+			t.Fatalf("failed to export session: %v", err)
+		}
+		paths = append(paths, path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read export: %v", err)
+		}
+		if string(data) != write(t, FormatMarkdown, doc) {
+			t.Errorf("%s contains %s", path, data)
+		}
+	}
+	want := []string{
+		filepath.Join(dir, "20240315-weekly-sync-notes-3f2a9c1e.md"),
+		filepath.Join(dir, "20240315-weekly-sync-notes-9b8c7d6e.md"),
+	}
+	if strings.Join(paths, "|") != strings.Join(want, "|") {
+		t.Errorf("got files %v, want %v", paths, want)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("got %d files, want 2", len(files))
+	}
+
+	if _, err := WriteFile(filepath.Join(dir, "missing"), FormatMarkdown, first); err == nil {
+		t.Error("exported into a missing directory")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"md", "HTML", "json", "txt"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("failed to parse format %q: %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("parsed unknown format")
+	}
+}
+
+// fineTuneBranch returns a branch with a question answered through a function call
+func fineTuneBranch() []chat.Message {
+	return []chat.Message{
+		{Role: chat.RoleUser, Content: "Weather in Moscow?"},
+		{Role: chat.RoleAssistant, FunctionCall: &chat.FunctionCall{Name: "weather", Arguments: []byte(`{"city":"Moscow"}`)}},
+		{Role: chat.RoleFunction, FunctionName: "weather", Content: `{"temperature":-5}`},
+		{Role: chat.RoleAssistant, Content: "It is -5 in Moscow"},
+	}
+}
+
+func TestNewFineTuneExample(t *testing.T) {
+	// A failed turn with an empty answer and a trailing question without one
+	conversation := []chat.Message{
+		{Role: chat.RoleSystem, Content: "Be brief"},
+		{Role: chat.RoleUser, Content: "Hi"},
+		{Role: chat.RoleAssistant, Content: ""},
+		{Role: chat.RoleUser, Content: "What is Go?"},
+		{Role: chat.RoleAssistant, Content: "A language"},
+		{Role: chat.RoleUser, Content: "Thanks"},
+	}
+
+	tests := []struct {
+		name   string
+		branch []chat.Message
+		opts   FineTuneOptions
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			branch: conversation,
+			want:   []string{"user:Hi", "assistant:", "user:What is Go?", "assistant:A language"},
+		},
+		{
+			name:   "include system",
+			branch: conversation,
+			opts:   FineTuneOptions{IncludeSystem: true},
+			want:   []string{"system:Be brief", "user:Hi", "assistant:", "user:What is Go?", "assistant:A language"},
+		},
+		{
+			name:   "drop failed",
+			branch: conversation,
+			opts:   FineTuneOptions{IncludeSystem: true, DropFailed: true},
+			want:   []string{"system:Be brief", "user:What is Go?", "assistant:A language"},
+		},
+		{
+			name:   "function call",
+			branch: fineTuneBranch(),
+			want:   []string{"user:Weather in Moscow?", "assistant:It is -5 in Moscow"},
+		},
+		{
+			name:   "function call with failed dropped",
+			branch: fineTuneBranch(),
+			opts:   FineTuneOptions{DropFailed: true},
+			want:   []string{"user:Weather in Moscow?", "assistant:It is -5 in Moscow"},
+		},
+		{
+			name:   "unanswered function call",
+			branch: fineTuneBranch()[:3],
+			opts:   FineTuneOptions{DropFailed: true},
+		},
+		{
+			name:   "only questions",
+			branch: []chat.Message{{Role: chat.RoleUser, Content: "Hi"}, {Role: chat.RoleUser, Content: "Anyone?"}},
+		},
+		{
+			name:   "answer without question",
+			branch: []chat.Message{{Role: chat.RoleSystem, Content: "Be brief"}, {Role: chat.RoleAssistant, Content: "Hello"}},
+			opts:   FineTuneOptions{IncludeSystem: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			example, ok := NewFineTuneExample("key", tt.branch, tt.opts)
+			var got []string
+			for _, m := range example.Messages {
+				got = append(got, string(m.Role)+":"+m.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || ok != (len(tt.want) > 0) {
+				t.Errorf("got example %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitFineTune(t *testing.T) {
+	var examples []FineTuneExample
+	for i := range 100 {
+		examples = append(examples, FineTuneExample{Key: fmt.Sprintf("session-%d", i)})
+	}
+
+	if train, valid := SplitFineTune(examples, 0); len(train) != len(examples) || len(valid) != 0 {
+		t.Errorf("got %d train and %d validation examples without validation", len(train), len(valid))
+	}
+
+	train, valid := SplitFineTune(examples, 0.3)
+	if len(train)+len(valid) != len(examples) || len(valid) == 0 || len(train) == 0 {
+		t.Fatalf("got %d train and %d validation examples", len(train), len(valid))
+	}
+	// An example lands in the same set however it is exported
+	inValid := make(map[string]bool)
+	for _, e := range valid {
+		inValid[e.Key] = true
+	}
+	for _, e := range examples {
+		_, alone := SplitFineTune([]FineTuneExample{e}, 0.3)
+		if (len(alone) == 1) != inValid[e.Key] {
+			t.Errorf("example %s changed its set when split alone", e.Key)
+		}
+	}
+}
+
+func TestWriteFineTune(t *testing.T) {
+	example, ok := NewFineTuneExample("session-1", []chat.Message{
+		{Role: chat.RoleUser, Content: "Is <b> & <i> HTML?"},
+		{Role: chat.RoleAssistant, Content: "Yes,\nboth are tags"},
+	}, FineTuneOptions{})
+	if !ok {
+		t.Fatal("no example")
+	}
+
+	var b bytes.Buffer
+	if err := WriteFineTune(&b, []FineTuneExample{example, example}); err != nil {
+		t.Fatalf("failed to write examples: %v", err)
+	}
+	line := `{"messages":[{"role":"user","content":"Is <b> & <i> HTML?"},{"role":"assistant","content":"Yes,\nboth are tags"}]}` + "\n"
+	if b.String() != line+line {
+		t.Errorf("got JSONL %s", b.String())
+	}
+}
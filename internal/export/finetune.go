@@ -0,0 +1,114 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// splitBuckets is the number of hash buckets used to split examples into train and validation sets
+const splitBuckets = 10000
+
+// FineTuneOptions control how conversations are turned into fine-tuning examples
+type FineTuneOptions struct {
+	// IncludeSystem keeps system prompts in the examples
+	IncludeSystem bool
+	// DropFailed drops turns where the answer is empty or missing
+	DropFailed bool
+}
+
+// FineTuneExample is a training example in the GigaChat/OpenAI chat fine-tuning format
+type FineTuneExample struct {
+	// Key identifies the example source and decides its train/validation split
+	Key      string            `json:"-"`
+	Messages []fineTuneMessage `json:"messages"`
+}
+
+// fineTuneMessage is a message of a fine-tuning example
+type fineTuneMessage struct {
+	Role    chat.Role `json:"role"`
+	Content string    `json:"content"`
+}
+
+// NewFineTuneExample converts the branch into a training example.
+// The example always ends with an answer, ok is false if nothing is left to train on
+func NewFineTuneExample(key string, branch []chat.Message, opts FineTuneOptions) (FineTuneExample, bool) {
+	example := FineTuneExample{Key: key}
+	asked := false
+	for i, m := range branch {
+		switch m.Role {
+		case chat.RoleSystem:
+			if !opts.IncludeSystem {
+				continue
+			}
+		case chat.RoleUser:
+			if opts.DropFailed && !answered(branch, i) {
+				continue
+			}
+			asked = true
+		case chat.RoleAssistant:
+			// Function calls are not answers to learn from, neither are answers without a question
+			if m.FunctionCall != nil || !asked || opts.DropFailed && !isAnswer(m) {
+				continue
+			}
+		default:
+			continue
+		}
+		example.Messages = append(example.Messages, fineTuneMessage{Role: m.Role, Content: m.Content})
+	}
+
+	// Trailing questions have nothing to learn from
+	for len(example.Messages) > 0 && example.Messages[len(example.Messages)-1].Role != chat.RoleAssistant {
+		example.Messages = example.Messages[:len(example.Messages)-1]
+	}
+	return example, len(example.Messages) > 0
+}
+
+// SplitFineTune splits examples into train and validation sets by the hash of their keys,
+// so the same example always lands in the same set
+func SplitFineTune(examples []FineTuneExample, validation float64) (train, valid []FineTuneExample) {
+	threshold := uint32(validation * splitBuckets)
+	for _, e := range examples {
+		h := fnv.New32a()
+		h.Write([]byte(e.Key))
+		if h.Sum32()%splitBuckets < threshold {
+			valid = append(valid, e)
+		} else {
+			train = append(train, e)
+		}
+	}
+	return train, valid
+}
+
+// WriteFineTune writes the examples as JSONL, an example per line
+func WriteFineTune(w io.Writer, examples []FineTuneExample) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, e := range examples {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to write fine-tuning example %s: %w", e.Key, err)
+		}
+	}
+	return nil
+}
+
+// answered reports whether the question at i gets a non-empty answer.
+// A failed turn is a question without one, the function calls and results made on the way are skipped
+func answered(branch []chat.Message, i int) bool {
+	for _, m := range branch[i+1:] {
+		if m.FunctionCall != nil || m.Role == chat.RoleFunction {
+			continue
+		}
+		return isAnswer(m)
+	}
+	return false
+}
+
+// isAnswer reports whether the message is a non-empty assistant answer
+func isAnswer(m chat.Message) bool {
+	return m.Role == chat.RoleAssistant && strings.TrimSpace(m.Content) != ""
+}
@@ -0,0 +1,53 @@
+package export
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// htmlTemplate renders a session as a standalone HTML page
+var htmlTemplate = template.Must(template.New("session").Funcs(template.FuncMap{
+	"meta": messageMeta,
+	"role": roleTitle,
+	"time": formatTime,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Doc.Session.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; color: #222; }
+.meta { color: #777; font-size: 0.85em; }
+.message { border-radius: 6px; padding: 0.5em 1em; margin: 1em 0; }
+.user { background: #eef3ff; }
+.assistant { background: #f4f4f4; }
+.system { background: #fff6e0; }
+.content { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Doc.Session.Name}}</h1>
+<p class="meta">
+Session {{.Doc.Session.ID}} · created {{time .Doc.Session.Timestamp}} · {{len .Doc.Messages}} messages ·
+{{.Usage.TotalTokens}} tokens (prompt {{.Usage.PromptTokens}}, completion {{.Usage.CompletionTokens}})
+</p>
+{{range .Doc.Messages}}
+<div class="message {{.Role}}">
+<h3>{{role .Role}}</h3>
+<p class="meta">{{meta .}}</p>
+<div class="content">{{.Content}}</div>
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// writeHTML writes the document as a standalone HTML page
+func writeHTML(w io.Writer, doc *Document) error {
+	return htmlTemplate.Execute(w, struct {
+		Doc   *Document
+		Usage chat.Usage
+	}{Doc: doc, Usage: doc.Usage()})
+}
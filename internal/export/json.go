@@ -0,0 +1,85 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// jsonDocument is the JSON representation of an exported session
+type jsonDocument struct {
+	Session  jsonSession   `json:"session"`
+	Messages []jsonMessage `json:"messages"`
+}
+
+// jsonSession is the JSON representation of the session metadata
+type jsonSession struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	HeadID    string     `json:"head_id,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+	Messages  int        `json:"messages"`
+	Usage     chat.Usage `json:"usage"`
+}
+
+// jsonMessage is the JSON representation of a message
+type jsonMessage struct {
+	ID        string      `json:"id"`
+	ParentID  string      `json:"parent_id,omitempty"`
+	Seq       int64       `json:"seq"`
+	Role      chat.Role   `json:"role"`
+	Content   string      `json:"content"`
+	Timestamp time.Time   `json:"timestamp"`
+	Model     chat.Model  `json:"model,omitempty"`
+	Usage     *chat.Usage `json:"usage,omitempty"`
+
+	FunctionCall *chat.FunctionCall `json:"function_call,omitempty"`
+	FunctionName string             `json:"name,omitempty"`
+	Attachments  []string           `json:"attachments,omitempty"`
+	InlinedFiles []string           `json:"inlined_files,omitempty"`
+	Images       []string           `json:"images,omitempty"`
+}
+
+// writeJSON writes the document as indented JSON
+func writeJSON(w io.Writer, doc *Document) error {
+	out := jsonDocument{
+		Session: jsonSession{
+			ID:        doc.Session.ID,
+			Name:      doc.Session.Name,
+			HeadID:    doc.Session.HeadID,
+			Timestamp: doc.Session.Timestamp,
+			Messages:  len(doc.Messages),
+			Usage:     doc.Usage(),
+		},
+		Messages: make([]jsonMessage, 0, len(doc.Messages)),
+	}
+	for i := range doc.Messages {
+		m := &doc.Messages[i]
+		jm := jsonMessage{
+			ID:        m.ID,
+			ParentID:  m.ParentID,
+			Seq:       m.Seq,
+			Role:      m.Role,
+			Content:   m.Content,
+			Timestamp: m.Timestamp,
+			Model:     m.Model,
+
+			FunctionCall: m.FunctionCall,
+			FunctionName: m.FunctionName,
+			Attachments:  m.Attachments,
+			InlinedFiles: m.InlinedFiles,
+			Images:       m.Images,
+		}
+		if m.Usage.TotalTokens > 0 {
+			jm.Usage = &m.Usage
+		}
+		out.Messages = append(out.Messages, jm)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(out)
+}
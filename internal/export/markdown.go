@@ -0,0 +1,27 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// writeMarkdown writes the document as Markdown
+func writeMarkdown(w io.Writer, doc *Document) error {
+	bw := bufio.NewWriter(w)
+	usage := doc.Usage()
+
+	fmt.Fprintf(bw, "# %s\n\n", doc.Session.Name)
+	fmt.Fprintf(bw, "- Session: `%s`\n", doc.Session.ID)
+	fmt.Fprintf(bw, "- Created: %s\n", formatTime(doc.Session.Timestamp))
+	fmt.Fprintf(bw, "- Messages: %d\n", len(doc.Messages))
+	fmt.Fprintf(bw, "- Tokens: %d (prompt %d, completion %d)\n",
+		usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+
+	for _, m := range doc.Messages {
+		fmt.Fprintf(bw, "\n## %s\n\n", roleTitle(m.Role))
+		fmt.Fprintf(bw, "_%s_\n\n", messageMeta(m))
+		fmt.Fprintf(bw, "%s\n", m.Content)
+	}
+	return bw.Flush()
+}
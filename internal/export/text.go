@@ -0,0 +1,29 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// textSeparator separates messages in plain text exports
+var textSeparator = strings.Repeat("-", 72)
+
+// writeText writes the document as plain text
+func writeText(w io.Writer, doc *Document) error {
+	bw := bufio.NewWriter(w)
+	usage := doc.Usage()
+
+	fmt.Fprintf(bw, "%s\n", doc.Session.Name)
+	fmt.Fprintf(bw, "Session: %s\n", doc.Session.ID)
+	fmt.Fprintf(bw, "Created: %s\n", formatTime(doc.Session.Timestamp))
+	fmt.Fprintf(bw, "Messages: %d\n", len(doc.Messages))
+	fmt.Fprintf(bw, "Tokens: %d (prompt %d, completion %d)\n",
+		usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+
+	for _, m := range doc.Messages {
+		fmt.Fprintf(bw, "\n%s\n%s [%s]\n\n%s\n", textSeparator, roleTitle(m.Role), messageMeta(m), m.Content)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,147 @@
+// Package httprecord captures HTTP interactions to a directory and serves them back,
+// so runs can be reproduced without the network. Secrets are redacted before anything is written
+package httprecord
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Redacted replaces secrets in recorded interactions
+	Redacted = "REDACTED"
+
+	interactionExt = ".json"
+	// bodyBase64 is the encoding of the bodies which are not UTF-8 text, e.g. uploaded files and images.
+	// Written as JSON strings they would come back corrupted
+	bodyBase64 = "base64"
+)
+
+// secretHeaders are the headers whose values are never written
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// secretFields matches JSON string fields holding secrets, e.g. issued access tokens
+var secretFields = regexp.MustCompile(`("(?:access_token|client_secret)"\s*:\s*)"[^"]*"`)
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding is base64 for binary bodies, empty for text
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// Response is a recorded HTTP response, streamed bodies are kept whole
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	// BodyEncoding is base64 for binary bodies, empty for text
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// redactHeader returns a copy of the header with the secret values replaced
+func redactHeader(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, key := range secretHeaders {
+		if _, ok := redacted[http.CanonicalHeaderKey(key)]; ok {
+			redacted.Set(key, Redacted)
+		}
+	}
+	return redacted
+}
+
+// redactBody returns the body with the secret JSON fields replaced
+func redactBody(body []byte) string {
+	return secretFields.ReplaceAllString(string(body), `${1}"`+Redacted+`"`)
+}
+
+// encodeBody returns the body to record and its encoding: text with the secrets redacted, or base64 of binary data
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return redactBody(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), bodyBase64
+}
+
+// decodeBody returns the recorded body as it was sent
+func decodeBody(body, encoding string) (string, error) {
+	switch encoding {
+	case "":
+		return body, nil
+	case bodyBase64:
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode body: %w", err)
+		}
+		return string(decoded), nil
+	default:
+		return "", fmt.Errorf("unsupported body encoding %q", encoding)
+	}
+}
+
+// writeInteraction writes the interaction to the numbered file in dir
+func writeInteraction(dir string, seq int64, interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal interaction: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%04d%s", seq, interactionExt))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write interaction: %w", err)
+	}
+	return nil
+}
+
+// readInteractions reads the interactions recorded in dir in the order they were made
+func readInteractions(dir string) ([]Interaction, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recordings directory: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), interactionExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	interactions := make([]Interaction, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read interaction %s: %w", name, err)
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(data, &interaction); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal interaction %s: %w", name, err)
+		}
+		if interaction.Request.Body, err = decodeBody(interaction.Request.Body, interaction.Request.BodyEncoding); err != nil {
+			return nil, fmt.Errorf("failed to read request of interaction %s: %w", name, err)
+		}
+		if interaction.Response.Body, err = decodeBody(interaction.Response.Body, interaction.Response.BodyEncoding); err != nil {
+			return nil, fmt.Errorf("failed to read response of interaction %s: %w", name, err)
+		}
+		interaction.Request.BodyEncoding, interaction.Response.BodyEncoding = "", ""
+		interactions = append(interactions, interaction)
+	}
+	return interactions, nil
+}
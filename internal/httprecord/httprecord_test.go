@@ -0,0 +1,160 @@
+package httprecord
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+)
+
+// chat asks the question and returns the streamed answer
+func chat(t *testing.T, client *gigachat.Client, question string) string {
+	t.Helper()
+	stream, err := client.CompletionStream(context.Background(), &gigachat.Request{
+		Model:    gigachat.ModelGigaChat,
+		Messages: []gigachat.Message{{Role: gigachat.RoleUser, Content: question}},
+	})
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	defer stream.Close()
+
+	var answer strings.Builder
+	for stream.Next() {
+		answer.WriteString(stream.Current().Choices[0].Delta.Content)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	return answer.String()
+}
+
+// newClient creates a client of the fake API sending requests with the transport
+func newClient(t *testing.T, srv *gigachattest.Server, baseURL string, transport http.RoundTripper) *gigachat.Client {
+	t.Helper()
+	httpClient := &http.Client{Transport: transport}
+	client, err := gigachat.NewClient(
+		gigachat.WithBaseURL(baseURL),
+		gigachat.WithHTTPClient(httpClient),
+		gigachat.WithTokenSource(&gigachat.OAuth{
+			ClientID:     gigachattest.ClientID,
+			ClientSecret: gigachattest.ClientSecret,
+			URL:          srv.OAuthURL(),
+			HTTPClient:   httpClient,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	srv := gigachattest.NewServer()
+	srv.ReplyText("first answer", "second answer")
+
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	client := newClient(t, srv, srv.BaseURL(), recorder)
+	recorded := []string{chat(t, client, "first"), chat(t, client, "second")}
+	srv.Close()
+
+	// The token request and both completions are recorded without secrets
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatalf("got %d recorded interactions, want 3", len(files))
+	}
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		for _, secret := range []string{"fake-token-", "Basic ", "Bearer "} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s contains %q", filepath.Base(file), secret)
+			}
+		}
+	}
+
+	// The server is gone, so the answers can only come from the recordings
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("failed to create replayer: %v", err)
+	}
+	client = newClient(t, srv, srv.BaseURL(), replayer)
+	replayed := []string{chat(t, client, "first"), chat(t, client, "second")}
+	if strings.Join(replayed, "|") != strings.Join(recorded, "|") {
+		t.Errorf("got replayed answers %v, want %v", replayed, recorded)
+	}
+
+	if _, err := client.Models(context.Background()); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("got error %v for request that was not recorded", err)
+	}
+}
+
+func TestRecordReplayBinary(t *testing.T) {
+	dir := t.TempDir()
+	srv := gigachattest.NewServer()
+	image := []byte("\x89PNG\r\n\x1a\n\xff\xfe\x00binary")
+	file := srv.AddFile("cat.png", "assistant", image)
+
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	client := newClient(t, srv, srv.BaseURL(), recorder)
+	if _, err := client.UploadFile(context.Background(), "upload.bin", bytes.NewReader(image), gigachat.FilePurposeGeneral); err != nil {
+		t.Fatalf("failed to upload file: %v", err)
+	}
+	if got := fileContent(t, client, file.ID); !bytes.Equal(got, image) {
+		t.Fatalf("got recorded content %q, want %q", got, image)
+	}
+	srv.Close()
+
+	// Binary bodies are recorded as base64
+	interactions, err := readInteractions(dir)
+	if err != nil {
+		t.Fatalf("failed to read interactions: %v", err)
+	}
+	if len(interactions) != 3 || !strings.Contains(interactions[1].Request.Body, string(image)) {
+		t.Fatalf("got interactions %+v, want the upload with the binary file", interactions)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "0003.json"))
+	if !strings.Contains(string(data), `"body_encoding": "base64"`) {
+		t.Errorf("content response recorded as %s", data)
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("failed to create replayer: %v", err)
+	}
+	client = newClient(t, srv, srv.BaseURL(), replayer)
+	if _, err := client.UploadFile(context.Background(), "upload.bin", bytes.NewReader(image), gigachat.FilePurposeGeneral); err != nil {
+		t.Fatalf("failed to replay upload: %v", err)
+	}
+	if got := fileContent(t, client, file.ID); !bytes.Equal(got, image) {
+		t.Errorf("got replayed content %q, want %q", got, image)
+	}
+}
+
+// fileContent downloads the content of the file
+func fileContent(t *testing.T, client *gigachat.Client, id string) []byte {
+	t.Helper()
+	content, err := client.FileContent(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get file content: %v", err)
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatalf("failed to read file content: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,100 @@
+package httprecord
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Recorder is an http.RoundTripper writing every interaction to a directory.
+// Responses are passed through as they arrive, so streamed answers keep streaming
+type Recorder struct {
+	dir  string
+	base http.RoundTripper
+	seq  atomic.Int64
+}
+
+// NewRecorder creates the directory and a Recorder sending requests with base,
+// http.DefaultTransport if nil
+func NewRecorder(dir string, base http.RoundTripper) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+	return &Recorder{dir: dir, base: base}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	seq := r.seq.Add(1)
+
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	base := r.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: redactHeader(req.Header),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(reqBody)
+	resp.Body = &recordedBody{body: resp.Body, save: func(body []byte) {
+		interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(body)
+		if err := writeInteraction(r.dir, seq, interaction); err != nil {
+			slog.Error("failed to record interaction", "url", interaction.Request.URL, "error", err)
+			return
+		}
+		slog.Debug("interaction recorded",
+			slog.Int64("seq", seq),
+			slog.String("method", interaction.Request.Method),
+			slog.String("url", interaction.Request.URL),
+		)
+	}}
+	return resp, nil
+}
+
+// recordedBody captures the response body as it is read and saves it once closed
+type recordedBody struct {
+	body io.ReadCloser
+	buf  bytes.Buffer
+	save func([]byte)
+	once sync.Once
+}
+
+// Read implements io.Reader
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+// Close implements io.Closer, the unread rest of the body is not recorded
+func (b *recordedBody) Close() error {
+	b.once.Do(func() { b.save(b.buf.Bytes()) })
+	return b.body.Close()
+}
@@ -0,0 +1,82 @@
+package httprecord
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Replayer is an http.RoundTripper serving recorded interactions instead of hitting the network.
+// Every request gets the first unused interaction with the same method and URL path
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer loads the interactions recorded in dir
+func NewReplayer(dir string) (*Replayer, error) {
+	interactions, err := readInteractions(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(interactions) == 0 {
+		return nil, fmt.Errorf("no recorded interactions found in %s", dir)
+	}
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	interaction, err := r.next(req)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("interaction replayed",
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+	)
+
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// next takes the first unused interaction matching the request
+func (r *Replayer) next(req *http.Request) (Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != req.Method {
+			continue
+		}
+		recorded, err := url.Parse(interaction.Request.URL)
+		if err != nil || recorded.Path != req.URL.Path {
+			continue
+		}
+		r.used[i] = true
+		return interaction, nil
+	}
+	return Interaction{}, fmt.Errorf("no recorded interaction left for %s %s", req.Method, req.URL.Path)
+}
@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// chatGPTSource prefixes the source ids of ChatGPT conversations
+const chatGPTSource = "chatgpt"
+
+// chatGPTConversation is a conversation of the ChatGPT conversations.json export
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+// chatGPTNode is a node of the conversation tree
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+// chatGPTMessage is a message of the conversation tree node
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug string `json:"model_slug"`
+	} `json:"metadata"`
+}
+
+// parseChatGPT converts the conversations of the ChatGPT export
+func parseChatGPT(r io.Reader, opts Options) ([]Conversation, error) {
+	var source []chatGPTConversation
+	if err := json.NewDecoder(r).Decode(&source); err != nil {
+		return nil, fmt.Errorf("failed to decode ChatGPT conversations: %w", err)
+	}
+
+	conversations := make([]Conversation, 0, len(source))
+	for i := range source {
+		conv, err := convertChatGPT(&source[i], opts)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conv)
+	}
+	return conversations, nil
+}
+
+// convertChatGPT walks the conversation tree from its roots, parents before children.
+// Nodes without importable content are skipped and their children are attached to the nearest imported ancestor
+func convertChatGPT(src *chatGPTConversation, opts Options) (*Conversation, error) {
+	sourceID := src.ConversationID
+	if sourceID == "" {
+		sourceID = src.ID
+	}
+	if sourceID == "" {
+		return nil, fmt.Errorf("ChatGPT conversation %q has no id", src.Title)
+	}
+
+	created := unixTime(src.CreateTime)
+	conv := &Conversation{
+		Session: chat.Session{
+			ID:        stableID(chatGPTSource, sourceID),
+			Name:      src.Title,
+			Timestamp: created,
+		},
+	}
+
+	// With flattening only the nodes on the path to the current node are kept
+	var onPath map[string]bool
+	if opts.Flatten && src.CurrentNode != "" {
+		onPath = make(map[string]bool)
+		for id := src.CurrentNode; id != ""; id = src.Mapping[id].Parent {
+			if onPath[id] {
+				return nil, fmt.Errorf("ChatGPT conversation %s has a cycle", sourceID)
+			}
+			onPath[id] = true
+		}
+	}
+
+	// importedIDs maps source node ids to the ids of imported messages,
+	// skipped nodes map to the message of their nearest imported ancestor
+	importedIDs := make(map[string]string)
+	var walk func(nodeID, parentID string, depth int) error
+	walk = func(nodeID, parentID string, depth int) error {
+		if depth > len(src.Mapping) {
+			return fmt.Errorf("ChatGPT conversation %s has a cycle", sourceID)
+		}
+		node, ok := src.Mapping[nodeID]
+		if !ok || (onPath != nil && !onPath[nodeID]) {
+			return nil
+		}
+
+		if message, ok := convertChatGPTMessage(&node, conv.Session.ID, created); ok {
+			message.ID = stableID(chatGPTSource, sourceID, nodeID)
+			message.ParentID = parentID
+			conv.Messages = append(conv.Messages, message)
+			parentID = message.ID
+		}
+		importedIDs[nodeID] = parentID
+
+		for _, childID := range node.Children {
+			if err := walk(childID, parentID, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, id := range rootNodes(src.Mapping) {
+		if err := walk(id, "", 0); err != nil {
+			return nil, err
+		}
+	}
+
+	conv.Session.HeadID = importedIDs[src.CurrentNode]
+	if conv.Session.HeadID == "" && len(conv.Messages) > 0 {
+		conv.Session.HeadID = conv.Messages[len(conv.Messages)-1].ID
+	}
+	if conv.Session.Name == "" {
+		conv.Session.Name = nameFromMessages(conv.Messages, "ChatGPT conversation")
+	}
+	return conv, nil
+}
+
+// convertChatGPTMessage converts the node message, ok is false if there is nothing to import
+func convertChatGPTMessage(node *chatGPTNode, sessionID string, fallback time.Time) (chat.Message, bool) {
+	if node.Message == nil {
+		return chat.Message{}, false
+	}
+	role, ok := mapRole(node.Message.Author.Role)
+	if !ok {
+		return chat.Message{}, false
+	}
+
+	content := node.Message.Content.Text
+	if content == "" {
+		var parts []string
+		for _, raw := range node.Message.Content.Parts {
+			// Only text parts are imported, images and other attachments are objects
+			var part string
+			if err := json.Unmarshal(raw, &part); err == nil && part != "" {
+				parts = append(parts, part)
+			}
+		}
+		content = strings.Join(parts, "\n")
+	}
+	if strings.TrimSpace(content) == "" {
+		return chat.Message{}, false
+	}
+
+	timestamp := unixTime(node.Message.CreateTime)
+	if timestamp.IsZero() {
+		timestamp = fallback
+	}
+	return chat.Message{
+		SessionID: sessionID,
+		Content:   content,
+		Role:      role,
+		Timestamp: timestamp,
+		Model:     chat.Model(node.Message.Metadata.ModelSlug),
+	}, true
+}
+
+// rootNodes returns the ids of nodes without a parent in a stable order
+func rootNodes(mapping map[string]chatGPTNode) []string {
+	var roots []string
+	for id, node := range mapping {
+		if _, ok := mapping[node.Parent]; node.Parent == "" || !ok {
+			roots = append(roots, id)
+		}
+	}
+	slices.Sort(roots)
+	return roots
+}
+
+// unixTime converts fractional unix seconds into time, zero seconds give zero time
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*float64(time.Second)))
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// sessionNameLen is the max length of a session name derived from its first question
+	sessionNameLen = 50
+	// byteOrderMark may precede the JSON of files saved on Windows
+	byteOrderMark = '\uFEFF'
+)
+
+// idNamespace is the namespace of the stable ids given to imported sessions and messages,
+// so importing the same file again does not duplicate them
+var idNamespace = uuid.MustParse("9f1c8a1e-5d0b-4c4e-8f55-6a3b1f2e7c10")
+
+// Format represents the import file format
+type Format string
+
+const (
+	// FormatAuto detects the format by the file content
+	FormatAuto Format = "auto"
+	// FormatChatGPT is the conversations.json file of the ChatGPT data export
+	FormatChatGPT Format = "chatgpt"
+	// FormatOpenAI is JSONL with an OpenAI style {"messages": [...]} conversation per line
+	FormatOpenAI Format = "openai"
+)
+
+// Options control how conversations are converted
+type Options struct {
+	// Flatten imports only the current branch of branching conversations
+	Flatten bool
+}
+
+// Conversation is an imported session with its messages, parents go before their children
+type Conversation struct {
+	Session  chat.Session
+	Messages []chat.Message
+}
+
+// Parse reads conversations from r in the given format
+func Parse(r io.Reader, format Format, opts Options) ([]Conversation, error) {
+	br := bufio.NewReader(r)
+	if format == FormatAuto {
+		var err error
+		if format, err = detectFormat(br); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case FormatChatGPT:
+		return parseChatGPT(br, opts)
+	case FormatOpenAI:
+		return parseOpenAI(br)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+// Save writes the conversation to the storage in one transaction.
+// Sessions and messages which are already stored are left untouched,
+// the head is moved to the current message only for a new session, so reimports keep the chosen branch
+func Save(sessions storage.SessionStore, conv *Conversation) error {
+	if err := sessions.Import(conv.Session, conv.Messages); err != nil {
+		return fmt.Errorf("failed to import session to storage: %w", err)
+	}
+	return nil
+}
+
+// detectFormat skips leading whitespace and byte order mark and looks at the first significant character:
+// ChatGPT exports are a JSON array, OpenAI style JSONL starts with an object
+func detectFormat(br *bufio.Reader) (Format, error) {
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			return "", fmt.Errorf("failed to detect import format: %w", err)
+		}
+		if unicode.IsSpace(r) || r == byteOrderMark {
+			continue
+		}
+		if err := br.UnreadRune(); err != nil {
+			return "", fmt.Errorf("failed to detect import format: %w", err)
+		}
+
+		switch r {
+		case '[':
+			return FormatChatGPT, nil
+		case '{':
+			return FormatOpenAI, nil
+		default:
+			return "", fmt.Errorf("failed to detect import format: unexpected %q", r)
+		}
+	}
+}
+
+// stableID returns a stable uuid for the source object id
+func stableID(parts ...string) string {
+	var b bytes.Buffer
+	for _, p := range parts {
+		b.WriteString(p)
+		b.WriteByte(0)
+	}
+	return uuid.NewSHA1(idNamespace, b.Bytes()).String()
+}
+
+// mapRole converts the source role into the chat role, ok is false for roles which are not imported
+func mapRole(role string) (chat.Role, bool) {
+	switch role {
+	case "user":
+		return chat.RoleUser, true
+	case "assistant":
+		return chat.RoleAssistant, true
+	case "system", "developer":
+		return chat.RoleSystem, true
+	default:
+		return "", false
+	}
+}
+
+// nameFromMessages derives a session name from the first line of the first user message
+func nameFromMessages(messages []chat.Message, fallback string) string {
+	for _, m := range messages {
+		if m.Role != chat.RoleUser {
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(m.Content), "\n")
+		if name := []rune(line); len(name) > sessionNameLen {
+			return string(name[:sessionNameLen]) + "…"
+		}
+		if line != "" {
+			return line
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/storage/storagetest"
+)
+
+// chatGPTExport is a conversation with a regenerated answer and a tool message,
+// the current node is on the branch of the first answer
+const chatGPTExport = `[{
+	"conversation_id": "conv-1",
+	"title": "",
+	"create_time": 1700000000.5,
+	"current_node": "a2",
+	"mapping": {
+		"root": {"id": "root", "message": null, "parent": null, "children": ["q1"]},
+		"q1": {"id": "q1", "parent": "root", "children": ["a1", "a1b"], "message": {
+			"author": {"role": "user"}, "create_time": 1700000001,
+			"content": {"content_type": "text", "parts": ["What is Go?\nAsking for a friend"]}}},
+		"a1": {"id": "a1", "parent": "q1", "children": ["q2"], "message": {
+			"author": {"role": "assistant"}, "create_time": 1700000002,
+			"content": {"content_type": "text", "parts": ["A language", {"asset_pointer": "file-1"}]},
+			"metadata": {"model_slug": "gpt-4o"}}},
+		"a1b": {"id": "a1b", "parent": "q1", "children": [], "message": {
+			"author": {"role": "assistant"}, "create_time": 1700000003,
+			"content": {"content_type": "text", "parts": ["A board game"]}}},
+		"q2": {"id": "q2", "parent": "a1", "children": ["tool"], "message": {
+			"author": {"role": "user"}, "create_time": 1700000004,
+			"content": {"content_type": "text", "parts": ["Who made it?"]}}},
+		"tool": {"id": "tool", "parent": "q2", "children": ["a2"], "message": {
+			"author": {"role": "tool"}, "content": {"content_type": "text", "parts": ["search results"]}}},
+		"a2": {"id": "a2", "parent": "tool", "children": [], "message": {
+			"author": {"role": "assistant"},
+			"content": {"content_type": "code", "text": "Google"}}}
+	}
+}]`
+
+// parseOne parses the input expecting a single conversation
+func parseOne(t *testing.T, input string, opts Options) Conversation {
+	t.Helper()
+	conversations, err := Parse(strings.NewReader(input), FormatAuto, opts)
+	if err != nil {
+		t.Fatalf("failed to parse conversations: %v", err)
+	}
+	if len(conversations) != 1 {
+		t.Fatalf("got %d conversations, want 1", len(conversations))
+	}
+	return conversations[0]
+}
+
+// contents returns the contents of the messages with their parents' contents, "" for roots
+func contents(messages []chat.Message) []string {
+	byID := make(map[string]string)
+	for _, m := range messages {
+		byID[m.ID] = m.Content
+	}
+	var got []string
+	for _, m := range messages {
+		got = append(got, byID[m.ParentID]+">"+m.Content)
+	}
+	return got
+}
+
+func TestChatGPT(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "branches",
+			want: []string{
+				">What is Go?\nAsking for a friend",
+				"What is Go?\nAsking for a friend>A language",
+				"A language>Who made it?",
+				"Who made it?>Google",
+				"What is Go?\nAsking for a friend>A board game",
+			},
+		},
+		{
+			name: "flatten",
+			opts: Options{Flatten: true},
+			want: []string{
+				">What is Go?\nAsking for a friend",
+				"What is Go?\nAsking for a friend>A language",
+				"A language>Who made it?",
+				"Who made it?>Google",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conv := parseOne(t, chatGPTExport, tt.opts)
+			if got := contents(conv.Messages); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got messages %q, want %q", got, tt.want)
+			}
+
+			// The head is the current node, the session is named after the first question
+			if conv.Session.HeadID != conv.Messages[3].ID || conv.Session.Name != "What is Go?" {
+				t.Errorf("got session %+v", conv.Session)
+			}
+			if conv.Messages[1].Model != "gpt-4o" || conv.Messages[1].Timestamp.Unix() != 1700000002 {
+				t.Errorf("got answer %+v", conv.Messages[1])
+			}
+			// Messages without a timestamp get the conversation creation time
+			if conv.Messages[3].Timestamp != conv.Session.Timestamp {
+				t.Errorf("got timestamp %v, want %v", conv.Messages[3].Timestamp, conv.Session.Timestamp)
+			}
+		})
+	}
+
+	cyclic := `[{"id": "conv-2", "current_node": "a", "mapping": {
+		"a": {"id": "a", "parent": "b", "children": ["b"]},
+		"b": {"id": "b", "parent": "a", "children": ["a"]}
+	}}]`
+	if _, err := Parse(strings.NewReader(cyclic), FormatChatGPT, Options{Flatten: true}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("got error %v for cyclic conversation, want a cycle", err)
+	}
+}
+
+func TestOpenAI(t *testing.T) {
+	input := "\uFEFF\n" + `{"model": "gpt-4o", "messages": [{"role": "system", "content": "Be brief"}, {"role": "user", "content": [{"type": "text", "text": "Hi"}]}, {"role": "tool", "content": "skipped"}, {"role": "assistant", "content": "Hello"}]}` + "\n"
+	conv := parseOne(t, input, Options{})
+	want := []string{">Be brief", "Be brief>Hi", "Hi>Hello"}
+	if got := contents(conv.Messages); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got messages %q, want %q", got, want)
+	}
+	if conv.Session.Name != "Hi" || conv.Session.HeadID != conv.Messages[2].ID || conv.Messages[2].Model != "gpt-4o" {
+		t.Errorf("got conversation %+v", conv)
+	}
+
+	// Lines without an id are identified by their content
+	if again := parseOne(t, input, Options{}); again.Session.ID != conv.Session.ID || again.Messages[2].ID != conv.Messages[2].ID {
+		t.Errorf("got ids %s and %s for the same line", again.Session.ID, conv.Session.ID)
+	}
+}
+
+func TestSaveTwice(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			conv := parseOne(t, chatGPTExport, Options{})
+			if err := Save(st.Sessions, &conv); err != nil {
+				t.Fatalf("failed to save conversation: %v", err)
+			}
+
+			// The user switches to the other answer, then the same export is imported again
+			regenerated := conv.Messages[4].ID
+			if err := st.Sessions.SetHead(conv.Session.ID, regenerated); err != nil {
+				t.Fatalf("failed to set head: %v", err)
+			}
+			again := parseOne(t, chatGPTExport, Options{})
+			if again.Session.ID != conv.Session.ID || again.Messages[0].ID != conv.Messages[0].ID {
+				t.Fatalf("got ids %s and %s for the same conversation", again.Session.ID, conv.Session.ID)
+			}
+			if err := Save(st.Sessions, &again); err != nil {
+				t.Fatalf("failed to save conversation again: %v", err)
+			}
+
+			sessions, err := st.Sessions.Read()
+			if err != nil || len(sessions) != 1 || sessions[0].HeadID != regenerated {
+				t.Errorf("got sessions %+v, %v, want one keeping the chosen head", sessions, err)
+			}
+			stored, err := st.Messages.ReadBySessionID(conv.Session.ID)
+			if err != nil || len(stored) != len(conv.Messages) {
+				t.Errorf("got %d messages, %v, want %d", len(stored), err, len(conv.Messages))
+			}
+			branch, err := st.Messages.ReadBranch(conv.Session.HeadID)
+			if err != nil || len(branch) != 4 {
+				t.Errorf("got branch %+v, %v", branch, err)
+			}
+		})
+	}
+}
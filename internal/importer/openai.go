@@ -0,0 +1,122 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/openai"
+)
+
+const (
+	// openAISource prefixes the source ids of OpenAI style conversations
+	openAISource = "openai"
+	// maxJSONLLineSize is the max size of a single JSONL conversation
+	maxJSONLLineSize = 64 << 20
+)
+
+// openAIConversation is a line of OpenAI style JSONL
+type openAIConversation struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Model    string          `json:"model"`
+	Created  int64           `json:"created"`
+	Messages []openAIMessage `json:"messages"`
+}
+
+// openAIMessage is a message of the OpenAI chat format
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// parseOpenAI converts OpenAI style JSONL, a conversation per line.
+// Conversations without an id are identified by the hash of their line
+func parseOpenAI(r io.Reader) ([]Conversation, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxJSONLLineSize)
+
+	imported := time.Now()
+	var conversations []Conversation
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		var src openAIConversation
+		if err := json.Unmarshal([]byte(line), &src); err != nil {
+			return nil, fmt.Errorf("failed to decode JSONL line %d: %w", lineNo, err)
+		}
+		sourceID := src.ID
+		if sourceID == "" {
+			sum := sha256.Sum256([]byte(line))
+			sourceID = hex.EncodeToString(sum[:])
+		}
+
+		conv, err := convertOpenAI(&src, sourceID, imported)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert JSONL line %d: %w", lineNo, err)
+		}
+		conversations = append(conversations, *conv)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSONL: %w", err)
+	}
+	return conversations, nil
+}
+
+// convertOpenAI converts the conversation into a single branch session.
+// The format has no timestamps, so the creation time or the import time is used
+func convertOpenAI(src *openAIConversation, sourceID string, imported time.Time) (*Conversation, error) {
+	created := imported
+	if src.Created > 0 {
+		created = time.Unix(src.Created, 0)
+	}
+
+	conv := &Conversation{
+		Session: chat.Session{
+			ID:        stableID(openAISource, sourceID),
+			Name:      src.Name,
+			Timestamp: created,
+		},
+	}
+
+	parentID := ""
+	for i, m := range src.Messages {
+		role, ok := mapRole(m.Role)
+		if !ok {
+			continue
+		}
+		content, err := openai.Content(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+
+		message := chat.Message{
+			ID:        stableID(openAISource, sourceID, fmt.Sprint(i)),
+			SessionID: conv.Session.ID,
+			ParentID:  parentID,
+			Content:   content,
+			Role:      role,
+			Timestamp: created,
+		}
+		if role == chat.RoleAssistant {
+			message.Model = chat.Model(src.Model)
+		}
+		conv.Messages = append(conv.Messages, message)
+		parentID = message.ID
+	}
+
+	conv.Session.HeadID = parentID
+	if conv.Session.Name == "" {
+		conv.Session.Name = nameFromMessages(conv.Messages, "Imported conversation")
+	}
+	return conv, nil
+}
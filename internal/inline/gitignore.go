@@ -0,0 +1,152 @@
+package inline
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignorer tells the files ignored by the .gitignore files of the root and its subdirectories
+type ignorer struct {
+	root  string
+	rules map[string][]ignoreRule // by directory relative to the root, read on demand
+}
+
+// ignoreRule is a pattern of a .gitignore file
+type ignoreRule struct {
+	dir      string // directory of the .gitignore file, relative to the root
+	pattern  string
+	negate   bool // the pattern starts with !, it includes the files again
+	dirOnly  bool // the pattern ends with /, it matches directories only
+	anchored bool // the pattern has a slash, it is relative to the directory of the file
+}
+
+// newIgnorer creates an ignorer of the root
+func newIgnorer(root string) *ignorer {
+	return &ignorer{root: root, rules: make(map[string][]ignoreRule)}
+}
+
+// ignored reports whether the slash separated path relative to the root is ignored.
+// Everything inside an ignored directory is ignored too, as well as the .git directory
+func (ig *ignorer) ignored(rel string, isDir bool) bool {
+	if rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if ig.match(parts[:i], true) {
+			return true
+		}
+	}
+	return ig.match(parts, isDir)
+}
+
+// match applies the rules of the directories above the path, the last matching rule wins
+func (ig *ignorer) match(parts []string, isDir bool) bool {
+	if parts[len(parts)-1] == ".git" {
+		return true
+	}
+	rel := strings.Join(parts, "/")
+	ignored := false
+	for i := range parts {
+		dir := "."
+		if i > 0 {
+			dir = strings.Join(parts[:i], "/")
+		}
+		for _, r := range ig.load(dir) {
+			if r.matches(rel, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// load returns the rules of the .gitignore file of the directory, none if there is no such file
+func (ig *ignorer) load(dir string) []ignoreRule {
+	if rules, ok := ig.rules[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	if f, err := os.Open(filepath.Join(ig.root, filepath.FromSlash(dir), ".gitignore")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if r, ok := parseRule(dir, scanner.Text()); ok {
+				rules = append(rules, r)
+			}
+		}
+		f.Close()
+	}
+	ig.rules[dir] = rules
+	return rules
+}
+
+// parseRule parses the line of the .gitignore file of the directory, false for blank lines and comments
+func parseRule(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	r := ignoreRule{dir: dir}
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		r.negate, line = true, rest
+	}
+	// A leading backslash escapes ! and #
+	line = strings.TrimPrefix(line, `\`)
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		r.dirOnly, line = true, rest
+	}
+	r.anchored = strings.Contains(line, "/")
+	r.pattern = strings.TrimPrefix(line, "/")
+	return r, r.pattern != ""
+}
+
+// matches reports whether the rule matches the slash separated path relative to the root
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.dir != "." {
+		sub, ok := strings.CutPrefix(rel, r.dir+"/")
+		if !ok {
+			return false
+		}
+		rel = sub
+	}
+	if r.anchored {
+		return matchGlob(r.pattern, rel)
+	}
+	return matchGlob(r.pattern, path.Base(rel))
+}
+
+// matchGlob reports whether the slash separated name matches the pattern,
+// where ** matches any number of directories and the other wildcards are the ones of path.Match
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches the path segments against the pattern segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
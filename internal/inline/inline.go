@@ -0,0 +1,292 @@
+// Package inline expands the file references of a prompt into the contents of the files.
+//
+// A reference is a word starting with @ naming a file, a directory or a glob pattern
+// where ** matches any number of directories, e.g. @main.go, @internal/client or @cmd/**/*.go.
+// The files are appended to the prompt as fenced code blocks. Files ignored by .gitignore are left out,
+// so are binary files, files larger than the size limit and files not fitting into the token budget
+package inline
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultMaxFileSize skips large files, they are hardly written by hand
+	defaultMaxFileSize = 64 * 1024
+	// defaultTokenBudget limits the tokens of all the files of a prompt, the model context is small
+	defaultTokenBudget = 8000
+	// bytesPerToken estimates the tokens of a text without asking the API.
+	// Non-latin text takes more bytes per token, so the estimate errs on the safe side
+	bytesPerToken = 4
+	// trailingPunctuation may follow a reference in a sentence
+	trailingPunctuation = ".,;:!?)'\""
+)
+
+// Options configures the expansion
+type Options struct {
+	// Root is the directory the references are relative to and limited to, the working directory if empty
+	Root string
+	// MaxFileSize skips the files larger than it in bytes
+	MaxFileSize int64
+	// TokenBudget limits the estimated tokens of all the included files
+	TokenBudget int
+}
+
+// Skipped is a referenced file left out of the prompt
+type Skipped struct {
+	Path   string
+	Reason string
+}
+
+// Result is the expanded prompt
+type Result struct {
+	// Content is the prompt followed by the included files
+	Content string
+	// Files are the included files, relative to the root
+	Files []string
+	// Skipped are the referenced files left out
+	Skipped []Skipped
+}
+
+// Expand appends the files referenced by the prompt to it.
+// Words starting with @ which name no file are not references and are kept as they are
+func Expand(prompt string, opts Options) (*Result, error) {
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve inline root: %w", err)
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = defaultMaxFileSize
+	}
+	if opts.TokenBudget <= 0 {
+		opts.TokenBudget = defaultTokenBudget
+	}
+
+	e := &expansion{root: root, opts: opts, ignore: newIgnorer(root), seen: make(map[string]bool)}
+	for _, word := range strings.Fields(prompt) {
+		if ref, ok := strings.CutPrefix(word, "@"); ok && ref != "" {
+			if err := e.reference(ref); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := &Result{Content: prompt, Files: e.files, Skipped: e.skipped}
+	if len(e.blocks) > 0 {
+		result.Content = prompt + "\n\n" + strings.Join(e.blocks, "\n")
+	}
+	return result, nil
+}
+
+// expansion collects the files referenced by a prompt
+type expansion struct {
+	root   string
+	opts   Options
+	ignore *ignorer
+	seen   map[string]bool // files referenced so far, a file is included once
+
+	files   []string
+	skipped []Skipped
+	blocks  []string
+	tokens  int
+}
+
+// reference includes the files of the reference
+func (e *expansion) reference(ref string) error {
+	if isGlob(ref) {
+		return e.glob(ref)
+	}
+
+	// A reference ending a sentence is followed by punctuation
+	name := ref
+	info, err := os.Stat(e.abs(name))
+	if err != nil {
+		name = strings.TrimRight(ref, trailingPunctuation)
+		if info, err = os.Stat(e.abs(name)); err != nil {
+			// Not a file reference, e.g. a mention
+			return nil
+		}
+	}
+
+	rel, ok := e.rel(name)
+	if !ok {
+		e.skip(name, "outside the working directory")
+		return nil
+	}
+	if e.ignore.ignored(rel, info.IsDir()) {
+		e.skip(rel, "ignored by .gitignore")
+		return nil
+	}
+	if info.IsDir() {
+		return e.walk(rel, path.Join(rel, "**"))
+	}
+	e.include(rel)
+	return nil
+}
+
+// glob includes the files matching the pattern
+func (e *expansion) glob(pattern string) error {
+	rel, ok := e.rel(pattern)
+	if !ok {
+		e.skip(pattern, "outside the working directory")
+		return nil
+	}
+	if _, err := path.Match(rel, ""); err != nil {
+		e.skip(pattern, "invalid pattern")
+		return nil
+	}
+
+	// Walk the directory before the first wildcard only
+	segments := strings.Split(rel, "/")
+	base := "."
+	for i, s := range segments {
+		if isGlob(s) {
+			base = path.Join(segments[:i]...)
+			break
+		}
+	}
+	if base == "" {
+		base = "."
+	}
+	if info, err := os.Stat(e.abs(base)); err != nil || !info.IsDir() {
+		e.skip(pattern, "no matching files")
+		return nil
+	}
+
+	before := len(e.files) + len(e.skipped)
+	if err := e.walk(base, rel); err != nil {
+		return err
+	}
+	if len(e.files)+len(e.skipped) == before {
+		e.skip(pattern, "no matching files")
+	}
+	return nil
+}
+
+// walk includes the files under the directory matching the pattern, both relative to the root
+func (e *expansion) walk(dir, pattern string) error {
+	err := filepath.WalkDir(e.abs(dir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := e.rel(p)
+		if d.IsDir() {
+			if rel != dir && e.ignore.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !matchGlob(pattern, rel) || e.ignore.ignored(rel, false) {
+			return nil
+		}
+		e.include(rel)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	return nil
+}
+
+// include appends the file to the prompt unless it is binary, too large or does not fit into the budget
+func (e *expansion) include(rel string) {
+	if e.seen[rel] {
+		return
+	}
+	e.seen[rel] = true
+
+	info, err := os.Stat(e.abs(rel))
+	if err != nil {
+		e.skip(rel, "unreadable")
+		return
+	}
+	if info.Size() > e.opts.MaxFileSize {
+		e.skip(rel, fmt.Sprintf("larger than %d bytes", e.opts.MaxFileSize))
+		return
+	}
+	data, err := os.ReadFile(e.abs(rel))
+	if err != nil {
+		e.skip(rel, "unreadable")
+		return
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		e.skip(rel, "binary")
+		return
+	}
+
+	block := codeBlock(rel, string(data))
+	tokens := (len(block) + bytesPerToken - 1) / bytesPerToken
+	if e.tokens+tokens > e.opts.TokenBudget {
+		e.skip(rel, fmt.Sprintf("exceeds the budget of %d tokens", e.opts.TokenBudget))
+		return
+	}
+	e.tokens += tokens
+	e.files = append(e.files, rel)
+	e.blocks = append(e.blocks, block)
+}
+
+// skip records the file left out
+func (e *expansion) skip(name, reason string) {
+	e.skipped = append(e.skipped, Skipped{Path: name, Reason: reason})
+}
+
+// abs returns the absolute path of the name relative to the root
+func (e *expansion) abs(name string) string {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(e.root, name)
+}
+
+// rel returns the slash separated path of the name relative to the root, false if it is outside the root
+func (e *expansion) rel(name string) (string, bool) {
+	rel, err := filepath.Rel(e.root, e.abs(name))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// codeBlock formats the file as a fenced code block titled with its path
+func codeBlock(name, content string) string {
+	// The fence must be longer than any backtick run of the content
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fmt.Sprintf("%s:\n%s%s\n%s%s\n", name, fence, language(name), content, fence)
+}
+
+// language returns the info string of the code block by the file extension
+func language(name string) string {
+	languages := map[string]string{
+		".go": "go", ".py": "python", ".js": "javascript", ".ts": "typescript", ".rs": "rust",
+		".java": "java", ".c": "c", ".h": "c", ".cpp": "cpp", ".sh": "sh", ".sql": "sql",
+		".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".md": "markdown",
+		".html": "html", ".css": "css",
+	}
+	return languages[strings.ToLower(path.Ext(name))]
+}
+
+// isGlob reports whether the name has wildcards
+func isGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
@@ -0,0 +1,116 @@
+package inline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":           "build/\n*.log\n!keep.log\n",
+		"main.go":              "package main\n",
+		"notes.md":             "Use ``` fences\n",
+		"keep.log":             "kept\n",
+		"debug.log":            "ignored\n",
+		"build/out.go":         "package build\n",
+		"cmd/app/app.go":       "package app\n",
+		"cmd/app/app_test.go":  "package app\n",
+		"cmd/app/.gitignore":   "*_test.go\n",
+		"cmd/app/data.bin":     "\x00\x01",
+		"cmd/tool/tool.go":     "package tool\n",
+		"internal/big.txt":     strings.Repeat("x", 200),
+		"internal/lib/lib.go":  "package lib\n",
+		"internal/lib/doc.txt": "docs\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		prompt  string
+		budget  int
+		files   []string
+		skipped []Skipped
+	}{
+		{
+			name:   "no references",
+			prompt: "mail me at me@example.com or ping @someone",
+		},
+		{
+			name:   "file with punctuation",
+			prompt: "What does @main.go do?",
+			files:  []string{"main.go"},
+		},
+		{
+			name:   "glob respects nested gitignore",
+			prompt: "Review @cmd/**/*.go",
+			files:  []string{"cmd/app/app.go", "cmd/tool/tool.go"},
+		},
+		{
+			name:    "directory",
+			prompt:  "Explain @internal",
+			files:   []string{"internal/lib/doc.txt", "internal/lib/lib.go"},
+			skipped: []Skipped{{Path: "internal/big.txt", Reason: "larger than 100 bytes"}},
+		},
+		{
+			name:    "ignored and binary files",
+			prompt:  "@debug.log @keep.log @build/out.go @cmd/app/data.bin",
+			files:   []string{"keep.log"},
+			skipped: []Skipped{{Path: "debug.log", Reason: "ignored by .gitignore"}, {Path: "build/out.go", Reason: "ignored by .gitignore"}, {Path: "cmd/app/data.bin", Reason: "binary"}},
+		},
+		{
+			name:    "outside root and no matches",
+			prompt:  "@../ @*.rs",
+			skipped: []Skipped{{Path: "../", Reason: "outside the working directory"}, {Path: "*.rs", Reason: "no matching files"}},
+		},
+		{
+			name:    "token budget",
+			prompt:  "@main.go @cmd/tool/tool.go @main.go",
+			budget:  10,
+			files:   []string{"main.go"},
+			skipped: []Skipped{{Path: "cmd/tool/tool.go", Reason: "exceeds the budget of 10 tokens"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Expand(tt.prompt, Options{Root: root, MaxFileSize: 100, TokenBudget: tt.budget})
+			if err != nil {
+				t.Fatalf("failed to expand: %v", err)
+			}
+			if !reflect.DeepEqual(result.Files, tt.files) {
+				t.Errorf("got files %v, want %v", result.Files, tt.files)
+			}
+			if !reflect.DeepEqual(result.Skipped, tt.skipped) {
+				t.Errorf("got skipped %v, want %v", result.Skipped, tt.skipped)
+			}
+			if len(tt.files) == 0 && result.Content != tt.prompt {
+				t.Errorf("got content %q, want the prompt", result.Content)
+			}
+			for _, f := range tt.files {
+				if !strings.Contains(result.Content, f+":\n```") {
+					t.Errorf("content %q lacks %s", result.Content, f)
+				}
+			}
+		})
+	}
+
+	result, err := Expand("@notes.md", Options{Root: root})
+	if err != nil {
+		t.Fatalf("failed to expand: %v", err)
+	}
+	want := "@notes.md\n\nnotes.md:\n````markdown\nUse ``` fences\n````\n"
+	if result.Content != want {
+		t.Errorf("got content %q, want %q", result.Content, want)
+	}
+}
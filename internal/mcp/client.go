@@ -0,0 +1,243 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// clientInfo introduces the client to the servers
+var clientInfo = Implementation{Name: "gigachatui", Version: "1.0.0"}
+
+// ErrClosed is returned by requests after the connection is closed
+var ErrClosed = errors.New("MCP connection closed")
+
+// Client is a connection to an MCP server, safe for concurrent use
+type Client struct {
+	// Server describes the connected server
+	Server Implementation
+
+	w     io.WriteCloser
+	close func() error
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan *message
+	err     error // set once the connection is broken
+	done    chan struct{}
+}
+
+// NewClient connects to the server reading its messages from r and writing to w
+// and initializes the session. Closing the client closes w
+func NewClient(ctx context.Context, r io.Reader, w io.WriteCloser) (*Client, error) {
+	c := newClient(r, w, w.Close)
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newClient starts reading the messages of the server
+func newClient(r io.Reader, w io.WriteCloser, close func() error) *Client {
+	c := &Client{
+		w:       w,
+		close:   close,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(r)
+	return c
+}
+
+// initialize negotiates the protocol version with the server
+func (c *Client) initialize(ctx context.Context) error {
+	params := initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    json.RawMessage("{}"),
+		ClientInfo:      clientInfo,
+	}
+	var result initializeResult
+	if err := c.call(ctx, methodInitialize, params, &result); err != nil {
+		return fmt.Errorf("failed to initialize MCP session: %w", err)
+	}
+	c.Server = result.ServerInfo
+	if err := c.write(&message{JSONRPC: jsonrpcVersion, Method: methodInitialized}); err != nil {
+		return fmt.Errorf("failed to initialize MCP session: %w", err)
+	}
+
+	slog.Debug("MCP session initialized",
+		slog.String("server", result.ServerInfo.Name),
+		slog.String("version", result.ServerInfo.Version),
+		slog.String("protocol", result.ProtocolVersion),
+	)
+	return nil
+}
+
+// ListTools returns all tools of the server
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var (
+		tools  []Tool
+		params listToolsParams
+	)
+	for {
+		var page listToolsResult
+		if err := c.call(ctx, methodToolsList, params, &page); err != nil {
+			return nil, fmt.Errorf("failed to list MCP tools: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		params.Cursor = page.NextCursor
+	}
+}
+
+// CallTool calls the tool of the server with the JSON object of arguments.
+// A tool failure is not an error, the result reports it
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, methodToolsCall, callToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, fmt.Errorf("failed to call MCP tool %s: %w", name, err)
+	}
+	return &result, nil
+}
+
+// Close closes the connection, a started server is stopped
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.close()
+}
+
+// call sends the request and decodes the result of its response into out
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := strconv.FormatInt(c.nextID, 10)
+	responses := make(chan *message, 1)
+	c.pending[id] = responses
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(&message{JSONRPC: jsonrpcVersion, ID: json.RawMessage(id), Method: method, Params: rawParams}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-responses:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if err := json.Unmarshal(resp.Result, out); err != nil {
+			return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		// Let the server stop working on the request
+		params, _ := json.Marshal(cancelledParams{RequestID: json.RawMessage(id), Reason: ctx.Err().Error()})
+		c.write(&message{JSONRPC: jsonrpcVersion, Method: methodCancelled, Params: params})
+		return ctx.Err()
+	}
+}
+
+// write sends the message as a line of JSON
+func (c *Client) write(m *message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// readLoop dispatches the messages of the server until the connection breaks
+func (c *Client) readLoop(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			slog.Debug("skipped malformed MCP message", slog.String("error", err.Error()))
+			continue
+		}
+
+		switch {
+		case m.isRequest():
+			c.answer(&m)
+		case m.isNotification():
+			slog.Debug("MCP notification", slog.String("method", m.Method))
+		default:
+			c.mu.Lock()
+			responses, ok := c.pending[string(m.ID)]
+			c.mu.Unlock()
+			if !ok {
+				continue
+			}
+			// The channel holds one response, a duplicate response must not block the loop
+			select {
+			case responses <- &m:
+			default:
+				slog.Debug("dropped duplicate MCP response", slog.String("id", string(m.ID)))
+			}
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	c.fail(fmt.Errorf("MCP connection broken: %w", err))
+}
+
+// answer responds to the requests of the server, only pings are supported
+func (c *Client) answer(req *message) {
+	resp := &message{JSONRPC: jsonrpcVersion, ID: req.ID}
+	if req.Method == methodPing {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if err := c.write(resp); err != nil {
+		slog.Debug("failed to answer MCP request", slog.String("method", req.Method), slog.String("error", err.Error()))
+	}
+}
+
+// fail breaks the connection with the error, pending and later requests return it
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/tools"
+)
+
+// stubServerEnv makes the test binary run as the stub MCP server
+const stubServerEnv = "MCP_STUB_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubServerEnv) == "1" {
+		runStubServer(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runStubServer serves a paged list of echo and fail tools until r is closed.
+// It pings the client and sends a notification before listing the tools, echoes of "twice" are answered twice
+func runStubServer(r io.Reader, w io.Writer) {
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || !req.isRequest() {
+			continue
+		}
+
+		var result any
+		switch req.Method {
+		case methodInitialize:
+			result = initializeResult{ProtocolVersion: ProtocolVersion, Capabilities: json.RawMessage("{}"), ServerInfo: Implementation{Name: "stub", Version: "0.1"}}
+		case methodToolsList:
+			var params listToolsParams
+			json.Unmarshal(req.Params, &params)
+			enc.Encode(message{JSONRPC: jsonrpcVersion, ID: json.RawMessage(`"ping-1"`), Method: methodPing})
+			enc.Encode(message{JSONRPC: jsonrpcVersion, Method: "notifications/tools/list_changed"})
+			if params.Cursor == "" {
+				result = listToolsResult{
+					Tools:      []Tool{{Name: "echo", Description: "Echoes the text", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)}},
+					NextCursor: "2",
+				}
+			} else {
+				result = listToolsResult{Tools: []Tool{{Name: "fail.always"}}}
+			}
+		case methodToolsCall:
+			var params callToolParams
+			json.Unmarshal(req.Params, &params)
+			var args struct{ Text string }
+			json.Unmarshal(params.Arguments, &args)
+			if params.Name == "echo" {
+				result = CallToolResult{Content: []Content{{Type: "text", Text: args.Text}}}
+			} else {
+				result = CallToolResult{Content: []Content{{Type: "text", Text: "always fails"}}, IsError: true}
+			}
+		default:
+			enc.Encode(message{JSONRPC: jsonrpcVersion, ID: req.ID, Error: &RPCError{Code: codeMethodNotFound, Message: "method not found"}})
+			continue
+		}
+		raw, _ := json.Marshal(result)
+		enc.Encode(message{JSONRPC: jsonrpcVersion, ID: req.ID, Result: raw})
+		if bytes.Contains(req.Params, []byte("twice")) {
+			enc.Encode(message{JSONRPC: jsonrpcVersion, ID: req.ID, Result: raw})
+		}
+	}
+}
+
+// startStub starts the test binary as the stub MCP server
+func startStub(t *testing.T) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Start(ctx, ServerConfig{Command: os.Args[0], Args: []string{"-test.run=^$"}, Env: map[string]string{stubServerEnv: "1"}})
+	if err != nil {
+		t.Fatalf("failed to start stub server: %v", err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	c := startStub(t)
+	ctx := context.Background()
+	if c.Server.Name != "stub" {
+		t.Errorf("got server %+v", c.Server)
+	}
+
+	serverTools, err := Tools(ctx, c, "my-stub")
+	if err != nil {
+		t.Fatalf("failed to list tools: %v", err)
+	}
+	registry, err := tools.NewRegistry(serverTools...)
+	if err != nil {
+		t.Fatalf("failed to register tools: %v", err)
+	}
+	var names []string
+	for _, f := range registry.Functions() {
+		names = append(names, f.Name)
+	}
+	if fmt.Sprint(names) != "[my_stub__echo my_stub__fail_always]" {
+		t.Errorf("got functions %v", names)
+	}
+
+	result, err := registry.Call(ctx, "my_stub__echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil || result != `{"result":"hi"}` {
+		t.Errorf("got echo result %s, error %v", result, err)
+	}
+	// A duplicate response is dropped without blocking the responses to later requests
+	for _, text := range []string{"twice", "once"} {
+		callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		result, err = registry.Call(callCtx, "my_stub__echo", json.RawMessage(`{"text":"`+text+`"}`))
+		cancel()
+		if err != nil || result != `{"result":"`+text+`"}` {
+			t.Errorf("got echo result %s, error %v", result, err)
+		}
+	}
+	result, err = registry.Call(ctx, "my_stub__fail_always", nil)
+	if err == nil || result != `{"error":"always fails"}` {
+		t.Errorf("got fail result %s, error %v", result, err)
+	}
+	var rpcErr *RPCError
+	if err := c.call(ctx, "unknown", struct{}{}, &struct{}{}); !errors.As(err, &rpcErr) || rpcErr.Code != codeMethodNotFound {
+		t.Errorf("got error %v for unknown method", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("failed to close client: %v", err)
+	}
+	if _, err := c.ListTools(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v after close, want %v", err, ErrClosed)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := t.TempDir() + "/mcp.json"
+	os.WriteFile(path, []byte(`{"mcpServers": {"files": {"command": "mcp-files", "args": ["--root", "."], "env": {"DEBUG": "1"}}}}`), 0o644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if files := cfg.Servers["files"]; files.Command != "mcp-files" || len(files.Args) != 2 || files.Env["DEBUG"] != "1" {
+		t.Errorf("got config %+v", cfg)
+	}
+
+	os.WriteFile(path, []byte(`{"mcpServers": {"broken": {}}}`), 0o644)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("loaded a server without a command")
+	}
+}
@@ -0,0 +1,87 @@
+// Package mcp implements the tools part of the Model Context Protocol over stdio.
+//
+// The client starts a server process, exchanges newline delimited JSON-RPC messages
+// with it, lists its tools and calls them. The tools are exposed to the assistant through function calling.
+// The server serves the tools of a registry to other MCP clients, e.g. editors and agents.
+// More info about the protocol can be found here: https://modelcontextprotocol.io/specification
+package mcp
+
+import "encoding/json"
+
+// ProtocolVersion is the MCP version spoken by the client
+const ProtocolVersion = "2024-11-05"
+
+// MCP methods
+const (
+	methodInitialize  = "initialize"
+	methodInitialized = "notifications/initialized"
+	methodCancelled   = "notifications/cancelled"
+	methodPing        = "ping"
+	methodToolsList   = "tools/list"
+	methodToolsCall   = "tools/call"
+)
+
+// Implementation names the client or the server
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// initializeParams are the params of the initialize request
+type initializeParams struct {
+	ProtocolVersion string          `json:"protocolVersion"`
+	Capabilities    json.RawMessage `json:"capabilities"`
+	ClientInfo      Implementation  `json:"clientInfo"`
+}
+
+// initializeResult is the result of the initialize request
+type initializeResult struct {
+	ProtocolVersion string          `json:"protocolVersion"`
+	Capabilities    json.RawMessage `json:"capabilities"`
+	ServerInfo      Implementation  `json:"serverInfo"`
+}
+
+// Tool is a tool of an MCP server
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// InputSchema is the JSON schema of the tool arguments
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// cancelledParams are the params of the cancelled notification
+type cancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// listToolsParams are the params of the tools/list request
+type listToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// listToolsResult is a page of the tools/list result
+type listToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// callToolParams are the params of the tools/call request
+type callToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// CallToolResult is the result of a tool call
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	// IsError reports that the tool failed, Content describes the error
+	IsError bool `json:"isError,omitempty"`
+}
+
+// Content is a piece of a tool result, only text content is passed to the assistant
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// stopTimeout is the time a server has to exit once its stdin is closed before it is killed
+const stopTimeout = 2 * time.Second
+
+// ServerConfig describes how to start an MCP server
+type ServerConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// Config lists the MCP servers by name, in the config file format shared by MCP clients
+type Config struct {
+	Servers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// LoadConfig reads the MCP servers config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse MCP config %s: %w", path, err)
+	}
+	for name, server := range cfg.Servers {
+		if server.Command == "" {
+			return nil, fmt.Errorf("MCP server %s has no command", name)
+		}
+	}
+	return &cfg, nil
+}
+
+// Start starts the server process and connects to it over its stdin and stdout.
+// The server stderr is logged at the debug level, closing the client stops the server
+func Start(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MCP server stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MCP server stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MCP server stderr: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", cfg.Command, err)
+	}
+	go logStderr(cfg.Command, stderr)
+
+	c := newClient(stdout, stdin, func() error { return stop(cmd, stdin) })
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// stop closes the server stdin, which asks it to exit, and kills it if it does not
+func stop(cmd *exec.Cmd, stdin io.Closer) error {
+	stdin.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return fmt.Errorf("failed to stop MCP server: %w", err)
+		}
+		return nil
+	case <-time.After(stopTimeout):
+		if err := cmd.Process.Kill(); err != nil {
+			return fmt.Errorf("failed to kill MCP server: %w", err)
+		}
+		<-exited
+		return nil
+	}
+}
+
+// logStderr logs the lines the server writes to stderr
+func logStderr(command string, stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		slog.Debug("MCP server stderr", slog.String("command", command), slog.String("line", scanner.Text()))
+	}
+}
@@ -0,0 +1,199 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/gennadis/gigachatui/internal/tools"
+)
+
+// Server serves the tools of the registry to an MCP client
+type Server struct {
+	info  Implementation
+	tools *tools.Registry
+}
+
+// NewServer creates a new Server introducing itself with the info
+func NewServer(info Implementation, registry *tools.Registry) *Server {
+	return &Server{info: info, tools: registry}
+}
+
+// serverSession is a connection of a client to the server
+type serverSession struct {
+	*Server
+	w       io.Writer
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // running requests by id
+}
+
+// Serve answers the requests read from r, writing the responses to w, until r is exhausted or ctx is done.
+// Requests are handled concurrently, a long tool call does not block the others and may be canceled by the client
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	// Requests still running when the client is gone are canceled
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	session := &serverSession{Server: s, w: w, cancels: make(map[string]context.CancelFunc)}
+
+	lines := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		errc <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			if err != nil {
+				return fmt.Errorf("failed to read MCP request: %w", err)
+			}
+			return nil
+		case line := <-lines:
+			var m message
+			if err := json.Unmarshal(line, &m); err != nil {
+				session.respond(&message{ID: json.RawMessage("null"), Error: &RPCError{Code: codeParseError, Message: "parse error"}})
+				continue
+			}
+			switch {
+			case m.isRequest():
+				reqCtx, reqCancel := context.WithCancel(ctx)
+				session.track(string(m.ID), reqCancel)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer session.untrack(string(m.ID))
+					session.respond(session.handle(reqCtx, &m))
+				}()
+			case m.isNotification():
+				session.notified(&m)
+			}
+		}
+	}
+}
+
+// handle answers the request
+func (s *serverSession) handle(ctx context.Context, req *message) *message {
+	resp := &message{ID: req.ID}
+	var (
+		result any
+		err    error
+	)
+	switch req.Method {
+	case methodInitialize:
+		// The server offers tools only
+		result = initializeResult{ProtocolVersion: ProtocolVersion, Capabilities: json.RawMessage(`{"tools":{}}`), ServerInfo: s.info}
+	case methodPing:
+		result = struct{}{}
+	case methodToolsList:
+		result = s.listTools()
+	case methodToolsCall:
+		result, err = s.callTool(ctx, req.Params)
+	default:
+		err = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+
+	if err != nil {
+		rpcErr, ok := err.(*RPCError)
+		if !ok {
+			rpcErr = &RPCError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	if resp.Result, err = json.Marshal(result); err != nil {
+		resp.Error = &RPCError{Code: codeInvalidParams, Message: fmt.Sprintf("failed to marshal result: %v", err)}
+	}
+	return resp
+}
+
+// listTools describes the tools of the registry
+func (s *serverSession) listTools() listToolsResult {
+	result := listToolsResult{Tools: []Tool{}}
+	for _, f := range s.tools.Functions() {
+		result.Tools = append(result.Tools, Tool{Name: f.Name, Description: f.Description, InputSchema: f.Parameters})
+	}
+	return result
+}
+
+// callTool calls the tool of the registry, a failed call is a result reporting the error
+func (s *serverSession) callTool(ctx context.Context, rawParams json.RawMessage) (CallToolResult, error) {
+	var params callToolParams
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return CallToolResult{}, fmt.Errorf("invalid tools/call params: %w", err)
+	}
+	content, err := s.tools.Call(ctx, params.Name, params.Arguments)
+	if err != nil {
+		return CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	return CallToolResult{Content: []Content{{Type: "text", Text: content}}}, nil
+}
+
+// notified handles the notification, only cancellations matter to the server
+func (s *serverSession) notified(n *message) {
+	if n.Method != methodCancelled {
+		return
+	}
+	var params cancelledParams
+	if err := json.Unmarshal(n.Params, &params); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[string(params.RequestID)]; ok {
+		cancel()
+	}
+}
+
+// track keeps the cancel function of the running request
+func (s *serverSession) track(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancels[id] = cancel
+}
+
+// untrack forgets the finished request
+func (s *serverSession) untrack(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+// respond writes the response as a line of JSON
+func (s *serverSession) respond(resp *message) {
+	resp.JSONRPC = jsonrpcVersion
+	b, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("failed to marshal MCP response", "error", err)
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.w.Write(append(b, '\n')); err != nil {
+		slog.Error("failed to write MCP response", "error", err)
+	}
+}
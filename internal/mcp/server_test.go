@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/gennadis/gigachatui/internal/tools"
+)
+
+// connect serves the registry and returns the client connected to it over pipes
+func connect(t *testing.T, registry *tools.Registry) *Client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(Implementation{Name: "test", Version: "1"}, registry).Serve(context.Background(), serverR, serverW)
+		serverW.Close()
+	}()
+	t.Cleanup(func() {
+		if err := <-served; err != nil {
+			t.Errorf("failed to serve: %v", err)
+		}
+	})
+
+	c, err := NewClient(context.Background(), clientR, clientW)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestServer(t *testing.T) {
+	blocked := make(chan struct{})
+	registry, err := tools.NewRegistry(
+		tools.NewFunc("echo", "Echoes the text", `{"type":"object","properties":{"text":{"type":"string"}}}`,
+			func(_ context.Context, args struct{ Text string }) (any, error) {
+				return map[string]string{"text": args.Text}, nil
+			}),
+		tools.NewFunc("fail", "Always fails", `{"type":"object"}`,
+			func(context.Context, struct{}) (any, error) {
+				return nil, errors.New("boom")
+			}),
+		tools.NewFunc("block", "Blocks until canceled", `{"type":"object"}`,
+			func(ctx context.Context, _ struct{}) (any, error) {
+				close(blocked)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	c := connect(t, registry)
+	ctx := context.Background()
+
+	if c.Server.Name != "test" {
+		t.Errorf("got server %+v", c.Server)
+	}
+	listed, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("failed to list tools: %v", err)
+	}
+	if len(listed) != 3 || listed[0].Name != "echo" || string(listed[0].InputSchema) != `{"type":"object","properties":{"text":{"type":"string"}}}` {
+		t.Errorf("got tools %+v", listed)
+	}
+
+	result, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil || result.IsError || len(result.Content) != 1 || result.Content[0].Text != `{"text":"hi"}` {
+		t.Errorf("got echo result %+v, error %v", result, err)
+	}
+	result, err = c.CallTool(ctx, "fail", nil)
+	if err != nil || !result.IsError || result.Content[0].Text != "boom" {
+		t.Errorf("got fail result %+v, error %v", result, err)
+	}
+
+	// Other requests are answered while a tool call runs, canceling the call notifies the server
+	callCtx, cancel := context.WithCancel(ctx)
+	called := make(chan error, 1)
+	go func() {
+		_, err := c.CallTool(callCtx, "block", nil)
+		called <- err
+	}()
+	<-blocked
+	if err := c.call(ctx, methodPing, struct{}{}, &struct{}{}); err != nil {
+		t.Errorf("failed to ping during a tool call: %v", err)
+	}
+	cancel()
+	if err := <-called; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v for canceled call", err)
+	}
+
+	var rpcErr *RPCError
+	if err := c.call(ctx, "resources/list", struct{}{}, &struct{}{}); !errors.As(err, &rpcErr) || rpcErr.Code != codeMethodNotFound {
+		t.Errorf("got error %v for unsupported method", err)
+	}
+}
@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/internal/tools"
+)
+
+// toolNameSeparator joins the server and the tool names in the function names
+const toolNameSeparator = "__"
+
+// remoteTool is a tool of an MCP server called through function calling
+type remoteTool struct {
+	client   *Client
+	tool     Tool
+	function gigachat.Function
+}
+
+// Tools lists the tools of the server as registry tools.
+// Their function names are prefixed with the server name, e.g. github__search_issues
+func Tools(ctx context.Context, c *Client, server string) ([]tools.Tool, error) {
+	listed, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]tools.Tool, 0, len(listed))
+	for _, t := range listed {
+		parameters := t.InputSchema
+		if len(parameters) == 0 {
+			parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out = append(out, &remoteTool{
+			client: c,
+			tool:   t,
+			function: gigachat.Function{
+				Name:        functionName(server) + toolNameSeparator + functionName(t.Name),
+				Description: t.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+	return out, nil
+}
+
+// Function implements the tools.Tool interface
+func (t *remoteTool) Function() gigachat.Function {
+	return t.function
+}
+
+// Call implements the tools.Tool interface, the text content of the result is returned
+func (t *remoteTool) Call(ctx context.Context, args json.RawMessage) (any, error) {
+	result, err := t.client.CallTool(ctx, t.tool.Name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var text []string
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			text = append(text, c.Text)
+		} else {
+			text = append(text, fmt.Sprintf("[%s content omitted]", c.Type))
+		}
+	}
+	if result.IsError {
+		return nil, errors.New(strings.Join(text, "\n"))
+	}
+	return strings.Join(text, "\n"), nil
+}
+
+// functionName replaces the characters not allowed in function names with underscores
+func functionName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
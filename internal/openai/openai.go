@@ -0,0 +1,39 @@
+// Package openai holds the parts of the OpenAI chat format shared by the compatible API and the importer
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ContentPart is a part of the message content given as an array
+type ContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Content returns the message text given either as a string or as an array of parts,
+// the text parts are joined with newlines and the others are dropped
+func Content(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+
+	var parts []ContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("failed to decode message content: %w", err)
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/client"
+	"github.com/gennadis/gigachatui/storage"
+)
+
+const (
+	defaultMessagesLimit = 50
+	maxMessagesLimit     = 500
+	activeBranch         = "active"
+)
+
+// sessionResponse is a session of the history API
+type sessionResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	HeadID    string    `json:"head_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// messageResponse is a message of the history API
+type messageResponse struct {
+	ID        string     `json:"id"`
+	SessionID string     `json:"session_id"`
+	ParentID  string     `json:"parent_id,omitempty"`
+	Seq       int64      `json:"seq"`
+	Role      chat.Role  `json:"role"`
+	Content   string     `json:"content"`
+	Timestamp time.Time  `json:"timestamp"`
+	Model     chat.Model `json:"model,omitempty"`
+	Usage     chat.Usage `json:"usage"`
+	Starred   bool       `json:"starred"`
+
+	FunctionCall *chat.FunctionCall `json:"function_call,omitempty"`
+	FunctionName string             `json:"name,omitempty"`
+	Attachments  []string           `json:"attachments,omitempty"`
+	InlinedFiles []string           `json:"inlined_files,omitempty"`
+	Images       []string           `json:"images,omitempty"`
+}
+
+// messagesPage is a page of session messages, NextAfter is the cursor of the next page
+type messagesPage struct {
+	Messages  []messageResponse `json:"messages"`
+	NextAfter int64             `json:"next_after,omitempty"`
+}
+
+// sessionRequest is the body of the create and rename session requests
+type sessionRequest struct {
+	Name string `json:"name"`
+}
+
+// messageRequest is the body of the post message request
+type messageRequest struct {
+	Content string `json:"content"`
+}
+
+// deltaEvent is a piece of the streamed answer
+type deltaEvent struct {
+	Content string `json:"content"`
+}
+
+// imageEvent is an image generated by the model, saved to the path
+type imageEvent struct {
+	Path string `json:"path"`
+}
+
+// finishEvent is the reason the model stopped generating the answer
+type finishEvent struct {
+	Reason string `json:"reason"`
+}
+
+// errorResponse is the error of the history API
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// registerHistoryRoutes registers the routes of the sessions and messages API
+func (s *Server) registerHistoryRoutes() {
+	s.mux.HandleFunc("GET /api/sessions", s.handleListSessions)
+	s.mux.HandleFunc("POST /api/sessions", s.handleCreateSession)
+	s.mux.HandleFunc("GET /api/sessions/{id}", s.handleGetSession)
+	s.mux.HandleFunc("PATCH /api/sessions/{id}", s.handleRenameSession)
+	s.mux.HandleFunc("DELETE /api/sessions/{id}", s.handleDeleteSession)
+	s.mux.HandleFunc("GET /api/sessions/{id}/messages", s.handleListMessages)
+	s.mux.HandleFunc("POST /api/sessions/{id}/messages", s.handlePostMessage)
+}
+
+// handleListSessions lists all sessions, newest first
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.client.SessionStorage.Read()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, newSessionResponse(session))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreateSession creates a new empty session
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req sessionRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeJSON(w, decodeStatus(err), errorResponse{Error: err.Error()})
+		return
+	}
+
+	session := chat.NewSession(req.Name)
+	if err := s.client.SessionStorage.Write(*session); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newSessionResponse(*session))
+}
+
+// handleGetSession returns the session
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, err := s.client.SessionStorage.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSessionResponse(session))
+}
+
+// handleRenameSession changes the name of the session
+func (s *Server) handleRenameSession(w http.ResponseWriter, r *http.Request) {
+	var req sessionRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeJSON(w, decodeStatus(err), errorResponse{Error: err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "name is required"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := s.client.SessionStorage.Rename(id, req.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.handleGetSession(w, r)
+}
+
+// handleDeleteSession deletes the session with all its messages
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if err := s.client.SessionStorage.Delete(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListMessages returns a page of the session messages ordered by sequence number.
+// The page starts after the seq given by the after parameter, branch=active limits it to the active branch
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	after, err := intParam(query.Get("after"), 0)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid after: %v", err)})
+		return
+	}
+	limit, err := intParam(query.Get("limit"), defaultMessagesLimit)
+	if err != nil || limit < 1 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid limit"})
+		return
+	}
+	limit = min(limit, maxMessagesLimit)
+
+	session, err := s.client.SessionStorage.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var messages []chat.Message
+	switch query.Get("branch") {
+	case "":
+		messages, err = s.client.MessageStorage.ReadBySessionID(session.ID)
+	case activeBranch:
+		if session.HeadID != "" {
+			messages, err = s.client.MessageStorage.ReadBranch(session.HeadID)
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "branch must be empty or " + activeBranch})
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page := messagesPage{Messages: []messageResponse{}}
+	for _, m := range messages {
+		if m.Seq <= after {
+			continue
+		}
+		if len(page.Messages) == int(limit) {
+			page.NextAfter = page.Messages[len(page.Messages)-1].Seq
+			break
+		}
+		page.Messages = append(page.Messages, newMessageResponse(m))
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handlePostMessage stores the user message in the active branch of the session
+// and streams the answer as server-sent events: delta, usage and finish events while it is generated,
+// function_call and function_result events with the stored messages of the functions called by the assistant,
+// image events with the paths of the generated images, then a message event with the stored answer and a done event, or an error event
+func (s *Server) handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	var req messageRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeJSON(w, decodeStatus(err), errorResponse{Error: err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "content is required"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.client.SessionStorage.Get(id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	startEventStream(w)
+	for e := range s.client.CompletionEvents(r.Context(), id, req.Content) {
+		var err error
+		switch e.Type {
+		case client.EventDelta:
+			err = writeEvent(w, "delta", deltaEvent{Content: e.Delta})
+		case client.EventUsage:
+			err = writeEvent(w, "usage", e.Usage)
+		case client.EventFinish:
+			err = writeEvent(w, "finish", finishEvent{Reason: e.FinishReason})
+		case client.EventImage:
+			err = writeEvent(w, "image", imageEvent{Path: e.Image})
+		case client.EventFunctionCall, client.EventFunctionResult:
+			err = writeEvent(w, string(e.Type), newMessageResponse(*e.Message))
+		case client.EventError:
+			slog.Error("failed to complete posted message", "session_id", id, "error", e.Err)
+			err = writeEvent(w, "error", errorResponse{Error: e.Err.Error()})
+		case client.EventDone:
+			answer := e.Message
+			// Read the answer back for the sequence number assigned by the storage
+			if branch, err := s.client.MessageStorage.ReadBranch(answer.ID); err == nil && len(branch) > 0 {
+				answer = &branch[len(branch)-1]
+			}
+			if err = writeEvent(w, "message", newMessageResponse(*answer)); err == nil {
+				err = writeRawEvent(w, "done", []byte("{}"))
+			}
+		}
+		if err != nil {
+			slog.Error("failed to write event", "type", e.Type, "error", err)
+			return
+		}
+	}
+}
+
+// newSessionResponse converts the session into the history API session
+func newSessionResponse(session chat.Session) sessionResponse {
+	return sessionResponse{
+		ID:        session.ID,
+		Name:      session.Name,
+		HeadID:    session.HeadID,
+		Timestamp: session.Timestamp,
+	}
+}
+
+// newMessageResponse converts the message into the history API message
+func newMessageResponse(m chat.Message) messageResponse {
+	return messageResponse{
+		ID:        m.ID,
+		SessionID: m.SessionID,
+		ParentID:  m.ParentID,
+		Seq:       m.Seq,
+		Role:      m.Role,
+		Content:   m.Content,
+		Timestamp: m.Timestamp,
+		Model:     m.Model,
+		Usage:     m.Usage,
+		Starred:   m.Starred,
+
+		FunctionCall: m.FunctionCall,
+		FunctionName: m.FunctionName,
+		Attachments:  m.Attachments,
+		InlinedFiles: m.InlinedFiles,
+		Images:       m.Images,
+	}
+}
+
+// intParam parses the query parameter or returns the fallback if it is empty
+func intParam(value string, fallback int64) (int64, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// writeError writes the storage error, missing records are reported as 404
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, storage.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+func TestAuthorization(t *testing.T) {
+	srv := newTestServer(t, "secret")
+
+	for _, header := range []string{"", "Bearer wrong", "secret", "Basic secret"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/sessions", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("got status %d for authorization %q, want 401", resp.StatusCode, header)
+		}
+	}
+
+	var sessions []sessionResponse
+	decode(t, srv.do(t, http.MethodGet, "/api/sessions", ""), http.StatusOK, &sessions)
+	if len(srv.fake.CompletionRequests()) != 0 {
+		t.Error("unauthorized requests reached the API")
+	}
+}
+
+func TestSessionsAPI(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	var created sessionResponse
+	decode(t, srv.do(t, http.MethodPost, "/api/sessions", `{"name": "first"}`), http.StatusCreated, &created)
+	if created.ID == "" || created.Name != "first" {
+		t.Fatalf("got created session %+v", created)
+	}
+
+	var sessions []sessionResponse
+	decode(t, srv.do(t, http.MethodGet, "/api/sessions", ""), http.StatusOK, &sessions)
+	if len(sessions) != 1 || sessions[0].ID != created.ID {
+		t.Errorf("got sessions %+v", sessions)
+	}
+
+	path := "/api/sessions/" + created.ID
+	var renamed sessionResponse
+	decode(t, srv.do(t, http.MethodPatch, path, `{"name": "renamed"}`), http.StatusOK, &renamed)
+	if renamed.ID != created.ID || renamed.Name != "renamed" {
+		t.Errorf("got renamed session %+v", renamed)
+	}
+	var got sessionResponse
+	decode(t, srv.do(t, http.MethodGet, path, ""), http.StatusOK, &got)
+	if got.Name != "renamed" {
+		t.Errorf("got session %+v after rename", got)
+	}
+
+	var errResp errorResponse
+	decode(t, srv.do(t, http.MethodPatch, path, `{"name": " "}`), http.StatusBadRequest, &errResp)
+	decode(t, srv.do(t, http.MethodPost, "/api/sessions", `{`), http.StatusBadRequest, &errResp)
+	decode(t, srv.do(t, http.MethodPost, "/api/sessions", `{"name": "`+strings.Repeat("x", maxRequestBody)+`"}`), http.StatusRequestEntityTooLarge, &errResp)
+
+	if resp := srv.do(t, http.MethodDelete, path, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("got status %d deleting session, want 204", resp.StatusCode)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		decode(t, srv.do(t, method, path, ""), http.StatusNotFound, &errResp)
+	}
+	decode(t, srv.do(t, http.MethodPatch, path, `{"name": "gone"}`), http.StatusNotFound, &errResp)
+}
+
+func TestMessagesPagination(t *testing.T) {
+	srv := newTestServer(t, "")
+	session := chat.NewSession("paged")
+	if err := srv.client.SessionStorage.Write(*session); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	// Five messages in a row with an alternative reply to the second one
+	var ids []string
+	parentID := ""
+	for i, content := range []string{"one", "two", "three", "four", "alternative"} {
+		message := chat.NewMessage(content, chat.RoleUser, session.ID)
+		message.ParentID = parentID
+		if i == 4 {
+			message.ParentID = ids[1]
+		}
+		if err := srv.client.MessageStorage.Write(*message); err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+		ids = append(ids, message.ID)
+		parentID = message.ID
+	}
+	if err := srv.client.SessionStorage.SetHead(session.ID, ids[3]); err != nil {
+		t.Fatalf("failed to set head: %v", err)
+	}
+
+	path := "/api/sessions/" + session.ID + "/messages"
+	tests := []struct {
+		query     string
+		want      []string
+		nextAfter int64
+	}{
+		{query: "", want: []string{"one", "two", "three", "four", "alternative"}},
+		{query: "?limit=2", want: []string{"one", "two"}, nextAfter: 2},
+		{query: "?after=2&limit=2", want: []string{"three", "four"}, nextAfter: 4},
+		{query: "?after=4&limit=2", want: []string{"alternative"}},
+		{query: "?after=5", want: []string{}},
+		{query: "?branch=active&limit=3", want: []string{"one", "two", "three"}, nextAfter: 3},
+		{query: "?branch=active&after=3", want: []string{"four"}},
+	}
+	for _, tt := range tests {
+		var page messagesPage
+		decode(t, srv.do(t, http.MethodGet, path+tt.query, ""), http.StatusOK, &page)
+		var got []string
+		for _, m := range page.Messages {
+			got = append(got, m.Content)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") || page.NextAfter != tt.nextAfter {
+			t.Errorf("%s: got %v next after %d, want %v next after %d", tt.query, got, page.NextAfter, tt.want, tt.nextAfter)
+		}
+	}
+
+	var errResp errorResponse
+	for _, query := range []string{"?after=x", "?limit=0", "?limit=x", "?branch=all"} {
+		decode(t, srv.do(t, http.MethodGet, path+query, ""), http.StatusBadRequest, &errResp)
+	}
+	decode(t, srv.do(t, http.MethodGet, "/api/sessions/missing/messages", ""), http.StatusNotFound, &errResp)
+}
+
+func TestPostMessage(t *testing.T) {
+	srv := newTestServer(t, "")
+	srv.fake.Reply(gigachattest.Reply{Chunks: []string{"Hel", "lo"}})
+	session := chat.NewSession("posted")
+	if err := srv.client.SessionStorage.Write(*session); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	path := "/api/sessions/" + session.ID + "/messages"
+	events := readEvents(t, srv.do(t, http.MethodPost, path, `{"content": "Hi"}`))
+
+	// Deltas come first, then the stored answer and the end of the stream
+	var (
+		names   []string
+		content strings.Builder
+		answer  messageResponse
+	)
+	for _, e := range events {
+		switch e.name {
+		case "delta":
+			var delta deltaEvent
+			if err := json.Unmarshal([]byte(e.data), &delta); err != nil {
+				t.Fatalf("failed to decode delta %s: %v", e.data, err)
+			}
+			content.WriteString(delta.Content)
+		case "message":
+			if err := json.Unmarshal([]byte(e.data), &answer); err != nil {
+				t.Fatalf("failed to decode message %s: %v", e.data, err)
+			}
+		case "usage", "finish":
+			continue
+		}
+		names = append(names, e.name)
+	}
+	if strings.Join(names, ",") != "delta,delta,message,done" {
+		t.Fatalf("got events %v, want deltas, message and done", names)
+	}
+	if content.String() != "Hello" || answer.Content != "Hello" || answer.Role != chat.RoleAssistant || answer.Seq != 2 {
+		t.Errorf("got deltas %q and answer %+v", content.String(), answer)
+	}
+
+	var page messagesPage
+	decode(t, srv.do(t, http.MethodGet, path+"?branch=active", ""), http.StatusOK, &page)
+	if len(page.Messages) != 2 || page.Messages[0].Content != "Hi" || page.Messages[1].ID != answer.ID {
+		t.Errorf("got active branch %+v", page.Messages)
+	}
+
+	var errResp errorResponse
+	decode(t, srv.do(t, http.MethodPost, path, `{"content": ""}`), http.StatusBadRequest, &errResp)
+	decode(t, srv.do(t, http.MethodPost, "/api/sessions/missing/messages", `{"content": "Hi"}`), http.StatusNotFound, &errResp)
+}
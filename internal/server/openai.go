@@ -0,0 +1,334 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/internal/openai"
+	"github.com/google/uuid"
+)
+
+// gigaChatModelPrefix is the common prefix of GigaChat model names,
+// other model names are replaced with the default model
+const gigaChatModelPrefix = "GigaChat"
+
+// openAIChatRequest is the OpenAI chat completions request
+type openAIChatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	N                   *int64          `json:"n"`
+	Stream              bool            `json:"stream"`
+	MaxTokens           *int64          `json:"max_tokens"`
+	MaxCompletionTokens *int64          `json:"max_completion_tokens"`
+	RepetitionPenalty   *float64        `json:"repetition_penalty"`
+	StreamOptions       *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// openAIMessage is a message of the OpenAI chat format, the content is a string or an array of parts
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// openAIResponseMessage is a message of the OpenAI chat response
+type openAIResponseMessage struct {
+	Role    gigachat.Role `json:"role,omitempty"`
+	Content string        `json:"content"`
+}
+
+// openAIChoice is a choice of the OpenAI chat response or stream chunk
+type openAIChoice struct {
+	Index        int32                  `json:"index"`
+	Message      *openAIResponseMessage `json:"message,omitempty"`
+	Delta        *openAIResponseMessage `json:"delta,omitempty"`
+	FinishReason *string                `json:"finish_reason"`
+}
+
+// openAIChatResponse is the OpenAI chat completion or chat completion chunk
+type openAIChatResponse struct {
+	ID      string          `json:"id"`
+	Object  string          `json:"object"`
+	Created int64           `json:"created"`
+	Model   gigachat.Model  `json:"model"`
+	Choices []openAIChoice  `json:"choices"`
+	Usage   *gigachat.Usage `json:"usage,omitempty"`
+}
+
+// openAIEmbeddingsRequest is the OpenAI embeddings request, the input is a string or an array of strings
+type openAIEmbeddingsRequest struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+// openAIEmbedding is an embedding of the OpenAI embeddings response
+type openAIEmbedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// openAIEmbeddingsResponse is the OpenAI embeddings response
+type openAIEmbeddingsResponse struct {
+	Object string            `json:"object"`
+	Data   []openAIEmbedding `json:"data"`
+	Model  gigachat.Model    `json:"model"`
+	Usage  struct {
+		PromptTokens int32 `json:"prompt_tokens"`
+		TotalTokens  int32 `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// openAIModel is a model of the OpenAI models list
+type openAIModel struct {
+	ID      gigachat.Model `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	OwnedBy string         `json:"owned_by"`
+}
+
+// openAIError is the OpenAI error response
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// registerOpenAIRoutes registers the OpenAI compatible proxy routes
+func (s *Server) registerOpenAIRoutes() {
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("GET /v1/models", s.handleModels)
+	s.mux.HandleFunc("POST /v1/embeddings", s.handleEmbeddings)
+}
+
+// handleChatCompletions translates the OpenAI chat completion into a GigaChat one
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openAIChatRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeOpenAIError(w, decodeStatus(err), err)
+		return
+	}
+	request, err := req.toChatRequest()
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id := "chatcmpl-" + uuid.NewString()
+	if !req.Stream {
+		resp, err := s.client.API.Completion(r.Context(), request)
+		if err != nil {
+			writeOpenAIError(w, upstreamStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, fromChatResponse(id, resp))
+		return
+	}
+
+	stream, err := s.client.API.CompletionStream(r.Context(), request)
+	if err != nil {
+		writeOpenAIError(w, upstreamStatus(err), err)
+		return
+	}
+	defer stream.Close()
+
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	startEventStream(w)
+	for stream.Next() {
+		chunk := stream.Current()
+		if err := writeEvent(w, "", fromStreamChunk(id, &chunk, includeUsage)); err != nil {
+			slog.Error("failed to write chat completion chunk", "error", err)
+			return
+		}
+	}
+	if err := stream.Err(); err != nil {
+		slog.Error("failed to stream chat completion", "error", err)
+		return
+	}
+	if err := writeRawEvent(w, "", []byte("[DONE]")); err != nil {
+		slog.Error("failed to write chat completion end", "error", err)
+	}
+}
+
+// handleModels lists the GigaChat models in the OpenAI format
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.client.API.Models(r.Context())
+	if err != nil {
+		writeOpenAIError(w, upstreamStatus(err), err)
+		return
+	}
+
+	models := make([]openAIModel, 0, len(resp.Data))
+	for _, m := range resp.Data {
+		models = append(models, openAIModel{ID: m.ID, Object: "model", OwnedBy: m.OwnedBy})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+// handleEmbeddings translates the OpenAI embeddings request into a GigaChat one
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req openAIEmbeddingsRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		writeOpenAIError(w, decodeStatus(err), err)
+		return
+	}
+
+	var input []string
+	if err := json.Unmarshal(req.Input, &input); err != nil {
+		var single string
+		if err := json.Unmarshal(req.Input, &single); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, errors.New("input must be a string or an array of strings"))
+			return
+		}
+		input = []string{single}
+	}
+
+	model := gigachat.Model(req.Model)
+	if !strings.HasPrefix(req.Model, string(gigachat.ModelEmbeddings)) {
+		model = gigachat.ModelEmbeddings
+	}
+	resp, err := s.client.API.Embeddings(r.Context(), &gigachat.EmbeddingsRequest{Model: model, Input: input})
+	if err != nil {
+		writeOpenAIError(w, upstreamStatus(err), err)
+		return
+	}
+
+	out := openAIEmbeddingsResponse{Object: "list", Model: resp.Model, Data: make([]openAIEmbedding, 0, len(resp.Data))}
+	for _, e := range resp.Data {
+		out.Data = append(out.Data, openAIEmbedding{Object: "embedding", Embedding: e.Embedding, Index: e.Index})
+		out.Usage.PromptTokens += e.Usage.PromptTokens
+	}
+	out.Usage.TotalTokens = out.Usage.PromptTokens
+	writeJSON(w, http.StatusOK, out)
+}
+
+// toChatRequest converts the OpenAI request into the GigaChat request with defaults for missing options
+func (req *openAIChatRequest) toChatRequest() (*gigachat.Request, error) {
+	if len(req.Messages) == 0 {
+		return nil, errors.New("messages are required")
+	}
+
+	messages := make([]chat.Message, 0, len(req.Messages))
+	for i, m := range req.Messages {
+		content, err := openai.Content(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		role := gigachat.Role(m.Role)
+		if role == "developer" {
+			role = gigachat.RoleSystem
+		}
+		messages = append(messages, chat.Message{Role: role, Content: content})
+	}
+
+	request := chat.NewRequest(messages)
+	if strings.HasPrefix(req.Model, gigaChatModelPrefix) {
+		request.Model = gigachat.Model(req.Model)
+	}
+	if req.Temperature != nil {
+		request.Temperature = *req.Temperature
+	}
+	if req.TopP != nil {
+		request.TopP = *req.TopP
+	}
+	if req.N != nil {
+		request.N = *req.N
+	}
+	if req.MaxTokens != nil {
+		request.MaxTokens = *req.MaxTokens
+	}
+	if req.MaxCompletionTokens != nil {
+		request.MaxTokens = *req.MaxCompletionTokens
+	}
+	if req.RepetitionPenalty != nil {
+		request.RepetitionPenalty = *req.RepetitionPenalty
+	}
+	return request, nil
+}
+
+// fromChatResponse converts the GigaChat response into the OpenAI chat completion
+func fromChatResponse(id string, resp *gigachat.Response) openAIChatResponse {
+	out := openAIChatResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created(resp.Created),
+		Model:   resp.Model,
+		Usage:   &resp.Usage,
+	}
+	for _, c := range resp.Choices {
+		out.Choices = append(out.Choices, openAIChoice{
+			Index:        c.Index,
+			Message:      &openAIResponseMessage{Role: gigachat.RoleAssistant, Content: c.Message.Content},
+			FinishReason: finishReason(c.FinishReason),
+		})
+	}
+	return out
+}
+
+// fromStreamChunk converts the GigaChat stream chunk into the OpenAI chat completion chunk
+func fromStreamChunk(id string, chunk *gigachat.StreamChunk, includeUsage bool) openAIChatResponse {
+	out := openAIChatResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: created(chunk.Created),
+		Model:   chunk.Model,
+	}
+	for _, c := range chunk.Choices {
+		out.Choices = append(out.Choices, openAIChoice{
+			Index:        c.Index,
+			Delta:        &openAIResponseMessage{Role: c.Delta.Role, Content: c.Delta.Content},
+			FinishReason: finishReason(c.FinishReason),
+		})
+	}
+	if includeUsage && chunk.Usage.TotalTokens > 0 {
+		out.Usage = &chunk.Usage
+	}
+	return out
+}
+
+// finishReason returns the finish reason or nil while the choice is not finished
+func finishReason(reason string) *string {
+	if reason == "" {
+		return nil
+	}
+	return &reason
+}
+
+// created returns the creation time or now if the API did not set it
+func created(ts int64) int64 {
+	if ts == 0 {
+		return time.Now().Unix()
+	}
+	return ts
+}
+
+// upstreamStatus returns the status code of the GigaChat API error or 502
+func upstreamStatus(err error) int {
+	var apiErr *gigachat.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return http.StatusBadGateway
+}
+
+// writeOpenAIError writes the error in the OpenAI error format
+func writeOpenAIError(w http.ResponseWriter, status int, err error) {
+	var resp openAIError
+	resp.Error.Message = err.Error()
+	resp.Error.Type = "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		resp.Error.Type = "api_error"
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+)
+
+func TestChatCompletions(t *testing.T) {
+	srv := newTestServer(t, "")
+	srv.fake.Reply(gigachattest.Reply{Content: "Hi there", Usage: gigachat.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}})
+
+	resp := srv.do(t, http.MethodPost, "/v1/chat/completions", `{
+		"model": "gpt-4o",
+		"temperature": 0.5,
+		"max_completion_tokens": 64,
+		"messages": [
+			{"role": "developer", "content": "Be brief"},
+			{"role": "user", "content": [{"type": "text", "text": "Hello"}, {"type": "image_url"}, {"type": "text", "text": "there"}]}
+		]
+	}`)
+	var completion openAIChatResponse
+	decode(t, resp, http.StatusOK, &completion)
+	if completion.Object != "chat.completion" || !strings.HasPrefix(completion.ID, "chatcmpl-") {
+		t.Errorf("got completion %+v", completion)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "Hi there" || *completion.Choices[0].FinishReason != "stop" {
+		t.Errorf("got choices %+v", completion.Choices)
+	}
+	if completion.Usage == nil || completion.Usage.TotalTokens != 5 {
+		t.Errorf("got usage %+v", completion.Usage)
+	}
+
+	// Unknown models are replaced, developer messages are system prompts and text parts are joined
+	request := srv.fake.CompletionRequests()[0]
+	if request.Model != gigachat.ModelGigaChat || request.Stream || request.Temperature != 0.5 || request.MaxTokens != 64 {
+		t.Errorf("got request %+v", request)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Role != gigachat.RoleSystem || request.Messages[1].Content != "Hello\nthere" {
+		t.Errorf("got request messages %+v", request.Messages)
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	srv := newTestServer(t, "")
+	srv.fake.Reply(gigachattest.Reply{Chunks: []string{"Hi", " there"}, Usage: gigachat.Usage{TotalTokens: 7}})
+
+	resp := srv.do(t, http.MethodPost, "/v1/chat/completions", `{
+		"model": "GigaChat-Pro",
+		"stream": true,
+		"stream_options": {"include_usage": true},
+		"messages": [{"role": "user", "content": "Hello"}]
+	}`)
+	events := readEvents(t, resp)
+	if len(events) != 3 || events[2].data != "[DONE]" {
+		t.Fatalf("got events %+v, want two chunks and the end", events)
+	}
+
+	var content strings.Builder
+	var last openAIChatResponse
+	for _, e := range events[:2] {
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(e.data), &chunk); err != nil {
+			t.Fatalf("failed to decode chunk %s: %v", e.data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" || chunk.Model != gigachat.ModelGigaChatPro {
+			t.Errorf("got chunk %+v", chunk)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		last = chunk
+	}
+	if content.String() != "Hi there" {
+		t.Errorf("got streamed content %q", content.String())
+	}
+	if last.Usage == nil || last.Usage.TotalTokens != 7 || *last.Choices[0].FinishReason != "stop" {
+		t.Errorf("got last chunk %+v", last)
+	}
+	if !srv.fake.CompletionRequests()[0].Stream {
+		t.Error("streamed completion requested without streaming")
+	}
+}
+
+func TestModelsAndEmbeddings(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	var models struct {
+		Object string        `json:"object"`
+		Data   []openAIModel `json:"data"`
+	}
+	decode(t, srv.do(t, http.MethodGet, "/v1/models", ""), http.StatusOK, &models)
+	if models.Object != "list" || len(models.Data) != 2 || models.Data[0].ID != gigachat.ModelGigaChat || models.Data[0].Object != "model" {
+		t.Errorf("got models %+v", models)
+	}
+
+	for _, input := range []string{`"one"`, `["one", "two words"]`} {
+		var embeddings openAIEmbeddingsResponse
+		decode(t, srv.do(t, http.MethodPost, "/v1/embeddings", `{"model": "text-embedding-3-small", "input": `+input+`}`), http.StatusOK, &embeddings)
+		if embeddings.Model != gigachat.ModelEmbeddings || len(embeddings.Data) != strings.Count(input, `"`)/2 {
+			t.Errorf("got embeddings %+v for %s", embeddings, input)
+		}
+		if embeddings.Usage.TotalTokens == 0 || embeddings.Data[0].Object != "embedding" || len(embeddings.Data[0].Embedding) == 0 {
+			t.Errorf("got embeddings %+v for %s", embeddings, input)
+		}
+	}
+	if got := srv.fake.Requests("/embeddings"); len(got) != 2 {
+		t.Errorf("got %d embeddings requests, want 2", len(got))
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	srv := newTestServer(t, "")
+	srv.fake.Fail("/chat/completions", http.StatusTooManyRequests, `{"status":429,"message":"too many requests"}`)
+	srv.fake.Fail("/models", http.StatusServiceUnavailable, `{"status":503,"message":"maintenance"}`)
+
+	large := `{"input": "` + strings.Repeat("x", maxRequestBody) + `"}`
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		wantType string
+	}{
+		{name: "invalid json", method: http.MethodPost, path: "/v1/chat/completions", body: `{`, status: http.StatusBadRequest, wantType: "invalid_request_error"},
+		{name: "no messages", method: http.MethodPost, path: "/v1/chat/completions", body: `{"messages": []}`, status: http.StatusBadRequest, wantType: "invalid_request_error"},
+		{name: "invalid content", method: http.MethodPost, path: "/v1/chat/completions", body: `{"messages": [{"role": "user", "content": 1}]}`, status: http.StatusBadRequest, wantType: "invalid_request_error"},
+		{name: "upstream client error", method: http.MethodPost, path: "/v1/chat/completions", body: `{"messages": [{"role": "user", "content": "Hi"}]}`, status: http.StatusTooManyRequests, wantType: "invalid_request_error"},
+		{name: "upstream server error", method: http.MethodGet, path: "/v1/models", status: http.StatusServiceUnavailable, wantType: "api_error"},
+		{name: "invalid input", method: http.MethodPost, path: "/v1/embeddings", body: `{"input": 1}`, status: http.StatusBadRequest, wantType: "invalid_request_error"},
+		{name: "large completion", method: http.MethodPost, path: "/v1/chat/completions", body: large, status: http.StatusRequestEntityTooLarge, wantType: "invalid_request_error"},
+		{name: "large embeddings", method: http.MethodPost, path: "/v1/embeddings", body: large, status: http.StatusRequestEntityTooLarge, wantType: "invalid_request_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp openAIError
+			decode(t, srv.do(t, tt.method, tt.path, tt.body), tt.status, &resp)
+			if resp.Error.Type != tt.wantType || resp.Error.Message == "" {
+				t.Errorf("got error %+v, want type %s", resp.Error, tt.wantType)
+			}
+		})
+	}
+
+	// Failures to reach the API are bad gateway errors
+	srv.fake.Close()
+	var resp openAIError
+	decode(t, srv.do(t, http.MethodGet, "/v1/models", ""), http.StatusBadGateway, &resp)
+	if resp.Error.Type != "api_error" {
+		t.Errorf("got error %+v for unreachable API", resp.Error)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/client"
+)
+
+const (
+	bearerPrefix           = "Bearer "
+	contentTypeJSON        = "application/json"
+	contentTypeEventStream = "text/event-stream"
+	// maxRequestBody limits the size of the JSON request bodies
+	maxRequestBody = 1 << 20
+)
+
+// Server serves local HTTP APIs backed by the GigaChat client.
+// GigaChat credentials and token rotation stay inside the client's auth manager
+type Server struct {
+	client *client.Client
+	token  string
+	mux    *http.ServeMux
+}
+
+// New creates a new Server with all routes registered.
+// Requests must carry the token as a bearer token unless it is empty
+func New(gcc *client.Client, token string) *Server {
+	s := &Server{
+		client: gcc,
+		token:  token,
+		mux:    http.NewServeMux(),
+	}
+	s.registerOpenAIRoutes()
+	s.registerHistoryRoutes()
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("http request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	)
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid or missing bearer token"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized reports whether the request carries the server token
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// decodeRequest decodes the JSON request body into v, reading at most maxRequestBody bytes
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+	return nil
+}
+
+// decodeStatus returns the status code of the decodeRequest error, too large bodies are reported as 413
+func decodeStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// writeJSON writes the value as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to write JSON response", "error", err)
+	}
+}
+
+// startEventStream prepares the response for server-sent events
+func startEventStream(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", contentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeEvent writes a server-sent event with the JSON data and flushes it to the client
+func writeEvent(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeRawEvent(w, event, data)
+}
+
+// writeRawEvent writes a server-sent event with the raw data and flushes it to the client.
+// The event line is omitted for unnamed events
+func writeRawEvent(w http.ResponseWriter, event string, data []byte) error {
+	if event != "" {
+		if _, err := w.Write([]byte("event: " + event + "\n")); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write([]byte("data: " + string(data) + "\n\n")); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gennadis/gigachatui/gigachat"
+	"github.com/gennadis/gigachatui/gigachat/gigachattest"
+	"github.com/gennadis/gigachatui/internal/auth"
+	"github.com/gennadis/gigachatui/internal/client"
+	"github.com/gennadis/gigachatui/internal/config"
+	"github.com/gennadis/gigachatui/storage/storagetest"
+)
+
+// testServer is the local API served over HTTP, backed by the fake GigaChat API
+type testServer struct {
+	*httptest.Server
+	fake   *gigachattest.Server
+	client *client.Client
+	token  string
+}
+
+// newTestServer serves the local API requiring the token, if any, backed by a new fake
+func newTestServer(t *testing.T, token string) *testServer {
+	t.Helper()
+	fake := gigachattest.NewServer()
+	t.Cleanup(fake.Close)
+
+	authManager, err := auth.NewManager(context.Background(), fake.OAuthURL(), gigachattest.ClientID, gigachattest.ClientSecret, nil)
+	if err != nil {
+		t.Fatalf("failed to create auth manager: %v", err)
+	}
+	cfg := config.Config{
+		BaseURL:   fake.BaseURL(),
+		Timeouts:  gigachat.Timeouts{FirstToken: time.Second, Idle: time.Second},
+		ImagesDir: t.TempDir(),
+	}
+	st := storagetest.Memory()
+	gcc, err := client.NewClient(cfg, authManager, st.Sessions, st.Messages, st.Files)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	srv := httptest.NewServer(New(gcc, token))
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, fake: fake, client: gcc, token: token}
+}
+
+// do sends the request with the JSON body, if any, and returns the response
+func (s *testServer) do(t *testing.T, method, path, body string) *http.Response {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, s.URL+path, r)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", contentTypeJSON)
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", bearerPrefix+s.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decode checks the status of the response and decodes its JSON body into v
+func decode(t *testing.T, resp *http.Response, status int, v any) {
+	t.Helper()
+	if resp.StatusCode != status {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("got status %d with %s, want %d", resp.StatusCode, body, status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+// sseEvent is a server-sent event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents reads the server-sent events of the response until it ends
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != contentTypeEventStream {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("got content type %q with %s, want an event stream", ct, body)
+	}
+
+	var (
+		events  []sseEvent
+		current sseEvent
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	return events
+}
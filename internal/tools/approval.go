@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ErrDenied is returned when the user denies a tool call
+var ErrDenied = errors.New("tool call denied by the user")
+
+// shellSpecial are the characters making a command line run more than the approved command,
+// or making the shell pass other arguments than the words of the command line
+const shellSpecial = ";&|<>`$()\n'\"\\*?[{~"
+
+// Approval decides whether tool calls may run
+type Approval struct {
+	// Tools run without asking
+	Tools []string
+	// Commands are the commands run_shell runs without asking, e.g. "git status" or "ls -l".
+	// A command may be followed by more arguments, but not by flags which are not part of it
+	Commands []string
+	// Ask asks the user about the other calls, they are denied if nil
+	Ask func(ctx context.Context, name string, args json.RawMessage) (bool, error)
+}
+
+// Wrap makes the tool run only once its calls are approved
+func (a *Approval) Wrap(t Tool) Tool {
+	return &approvedTool{Tool: t, approval: a}
+}
+
+// approve reports whether the call may run
+func (a *Approval) approve(ctx context.Context, name string, args json.RawMessage) (bool, error) {
+	if slices.Contains(a.Tools, name) {
+		return true, nil
+	}
+	if name == RunShellName {
+		var shell runShellArgs
+		if json.Unmarshal(args, &shell) == nil && a.commandApproved(shell.Command) {
+			return true, nil
+		}
+	}
+	if a.Ask == nil {
+		return false, nil
+	}
+	return a.Ask(ctx, name, args)
+}
+
+// commandApproved reports whether the command line runs an approved command and nothing else.
+// The words of the command line must start with the words of the approved command, the rest must not be flags:
+// a flag may change what a harmless command does, e.g. git log --output overwrites a file
+func (a *Approval) commandApproved(command string) bool {
+	if strings.ContainsAny(command, shellSpecial) {
+		return false
+	}
+	args := strings.Fields(command)
+	for _, approved := range a.Commands {
+		words := strings.Fields(approved)
+		if len(words) == 0 || len(args) < len(words) || !slices.Equal(args[:len(words)], words) {
+			continue
+		}
+		isFlag := func(arg string) bool { return strings.HasPrefix(arg, "-") }
+		if !slices.ContainsFunc(args[len(words):], isFlag) {
+			return true
+		}
+	}
+	return false
+}
+
+// approvedTool is a Tool whose calls must be approved
+type approvedTool struct {
+	Tool
+	approval *Approval
+}
+
+// Call implements the Tool interface
+func (t *approvedTool) Call(ctx context.Context, args json.RawMessage) (any, error) {
+	name := t.Function().Name
+	ok, err := t.approval.approve(ctx, name, args)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDenied
+	}
+	return t.Tool.Call(ctx, args)
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Names of the built-in tools
+const (
+	ReadFileName = "read_file"
+	GrepName     = "grep"
+	FetchURLName = "fetch_url"
+	RunShellName = "run_shell"
+)
+
+const (
+	// defaultMaxOutput limits the size of a tool result, the model context is small
+	defaultMaxOutput = 32 * 1024
+	// defaultTimeout limits fetching a URL and running a shell command
+	defaultTimeout = time.Minute
+)
+
+// BuiltinOptions configures the built-in tools
+type BuiltinOptions struct {
+	// Root is the directory read_file and grep are limited to and run_shell runs in, the working directory if empty
+	Root string
+	// AllowedHosts are the hosts fetch_url may fetch from, with their subdomains. It fetches nothing if empty
+	AllowedHosts []string
+	// HTTPClient fetches the URLs, a client with the default timeout is used if nil
+	HTTPClient *http.Client
+	// MaxOutput limits the size of the file contents, matches, pages and command output in bytes
+	MaxOutput int
+	// Timeout limits fetching a URL and running a shell command
+	Timeout time.Duration
+}
+
+// Builtin returns the built-in tools by name
+func Builtin(opts BuiltinOptions) (map[string]Tool, error) {
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tools root: %w", err)
+	}
+	// Paths are checked against the real root, it may be reached through a symlink
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, fmt.Errorf("failed to resolve tools root: %w", err)
+	}
+	opts.Root = root
+	if opts.MaxOutput <= 0 {
+		opts.MaxOutput = defaultMaxOutput
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: opts.Timeout}
+	}
+
+	return map[string]Tool{
+		ReadFileName: newReadFile(opts),
+		GrepName:     newGrep(opts),
+		FetchURLName: newFetchURL(opts),
+		RunShellName: newRunShell(opts),
+	}, nil
+}
+
+// resolvePath resolves the path relative to the root and rejects paths outside of it.
+// Symlinks are followed, so a link under the root pointing outside of it is rejected too
+func resolvePath(root, path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if !insideRoot(root, path) {
+		return "", fmt.Errorf("path %s is outside of %s", path, root)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if !insideRoot(root, resolved) {
+		return "", fmt.Errorf("path %s links outside of %s", path, root)
+	}
+	return resolved, nil
+}
+
+// insideRoot reports whether the clean absolute path is the root or is under it
+func insideRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relPath returns the path relative to the root for the results
+func relPath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// truncate cuts the text to max bytes, keeping whole UTF-8 characters
+func truncate(text string, max int) (string, bool) {
+	if len(text) <= max {
+		return text, false
+	}
+	cut := strings.ToValidUTF8(text[:max], "")
+	return cut, true
+}
@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// callTool calls the tool and returns its result as JSON
+func callTool(t *testing.T, tool Tool, args string) (string, error) {
+	t.Helper()
+	result, err := tool.Call(context.Background(), json.RawMessage(args))
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("failed to marshal result: %v", err)
+	}
+	return string(b), nil
+}
+
+func TestBuiltin(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":         "package main\n\nfunc main() {}\n",
+		"lib/lib.go":      "package lib\n\nfunc Helper() {}\n",
+		"lib/README.md":   "func in docs\n",
+		".git/config":     "func hidden\n",
+		"bin/tool.bin":    "func\x00binary",
+		"large/notes.txt": strings.Repeat("x", 100),
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Links inside the root are followed, links pointing outside of it are not
+	outside := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(outside, []byte("secret key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "key")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(root, "home")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("main.go", filepath.Join(root, "entry.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "http://example.com/", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "hello page")
+	}))
+	defer page.Close()
+	pageURL, _ := url.Parse(page.URL)
+
+	builtin, err := Builtin(BuiltinOptions{Root: root, AllowedHosts: []string{pageURL.Hostname()}, MaxOutput: 80})
+	if err != nil {
+		t.Fatalf("failed to create built-in tools: %v", err)
+	}
+
+	tests := []struct {
+		tool    string
+		args    string
+		want    string
+		wantErr string
+	}{
+		{tool: ReadFileName, args: `{"path":"main.go"}`, want: `{"path":"main.go","content":"package main\n\nfunc main() {}\n"}`},
+		{tool: ReadFileName, args: `{"path":"large/notes.txt"}`, want: `"truncated":true`},
+		{tool: ReadFileName, args: `{"path":"../secret"}`, wantErr: "outside"},
+		{tool: ReadFileName, args: `{"path":"bin/tool.bin"}`, wantErr: "binary"},
+		{tool: ReadFileName, args: `{"path":"key"}`, wantErr: "links outside"},
+		{tool: ReadFileName, args: `{"path":"home/id_rsa"}`, wantErr: "links outside"},
+		{tool: ReadFileName, args: `{"path":"entry.go"}`, want: `{"path":"main.go","content":"package main\n\nfunc main() {}\n"}`},
+		{tool: GrepName, args: `{"pattern":"secret","path":"home"}`, wantErr: "links outside"},
+		{tool: GrepName, args: `{"pattern":"^func"}`, want: `{"matches":[{"file":"lib/README.md","line":1,"text":"func in docs"},{"file":"lib/lib.go","line":3,"text":"func Helper() {}"},{"file":"main.go","line":3,"text":"func main() {}"}]}`},
+		{tool: GrepName, args: `{"pattern":"^func","path":"lib","glob":"*.go"}`, want: `{"matches":[{"file":"lib/lib.go","line":3,"text":"func Helper() {}"}]}`},
+		{tool: GrepName, args: `{"pattern":"("}`, wantErr: "failed to compile pattern"},
+		{tool: FetchURLName, args: fmt.Sprintf(`{"url":%q}`, page.URL), want: `"content":"hello page"`},
+		{tool: FetchURLName, args: fmt.Sprintf(`{"url":%q}`, page.URL+"/away"), wantErr: "host example.com is not allowed"},
+		{tool: FetchURLName, args: `{"url":"https://example.com/"}`, wantErr: "host example.com is not allowed"},
+		{tool: FetchURLName, args: `{"url":"file:///etc/passwd"}`, wantErr: "unsupported URL scheme"},
+		{tool: RunShellName, args: `{"command":"ls lib"}`, want: `{"exit_code":0,"output":"README.md\nlib.go\n"}`},
+		{tool: RunShellName, args: `{"command":"echo oops; exit 3"}`, want: `{"exit_code":3,"output":"oops\n"}`},
+	}
+	for _, tt := range tests {
+		got, err := callTool(t, builtin[tt.tool], tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s(%s) error %v, want %q", tt.tool, tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s(%s) failed: %v", tt.tool, tt.args, err)
+			continue
+		}
+		if got != tt.want && !strings.Contains(got, tt.want) {
+			t.Errorf("%s(%s) = %s, want %s", tt.tool, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestApproval(t *testing.T) {
+	var asked []string
+	approval := &Approval{
+		Tools:    []string{"echo"},
+		Commands: []string{"git status", "ls", "ls -l", "git log", "go test"},
+		Ask: func(_ context.Context, name string, args json.RawMessage) (bool, error) {
+			asked = append(asked, string(args))
+			return false, nil
+		},
+	}
+	run := func(context.Context, json.RawMessage) (any, error) { return "ran", nil }
+	echo := approval.Wrap(NewFunc("echo", "", `{}`, run))
+	shell := approval.Wrap(NewFunc(RunShellName, "", `{}`, run))
+
+	tests := []struct {
+		tool    Tool
+		args    string
+		wantRun bool
+	}{
+		{tool: echo, args: `{}`, wantRun: true},
+		{tool: shell, args: `{"command":"ls"}`, wantRun: true},
+		{tool: shell, args: `{"command":"  git   status  "}`, wantRun: true},
+		{tool: shell, args: `{"command":"ls lib"}`, wantRun: true},
+		{tool: shell, args: `{"command":"ls -l lib"}`, wantRun: true},
+		{tool: shell, args: `{"command":"ls -la"}`},
+		{tool: shell, args: `{"command":"git status --short"}`},
+		{tool: shell, args: `{"command":"git statusx"}`},
+		{tool: shell, args: `{"command":"ls && rm -rf /"}`},
+		{tool: shell, args: `{"command":"ls $(rm -rf /)"}`},
+		{tool: shell, args: `{"command":"rm -rf /"}`},
+		{tool: shell, args: `{"command":"git log --output=/etc/passwd"}`},
+		{tool: shell, args: `{"command":"git log -p --output /tmp/x"}`},
+		{tool: shell, args: `{"command":"go test -exec=/tmp/evil ./..."}`},
+		{tool: shell, args: `{"command":"go test ./... -exec /tmp/evil"}`},
+		{tool: shell, args: `{"command":"ls '-la'"}`},
+		{tool: shell, args: `{"command":"ls \\-la"}`},
+		{tool: shell, args: `{"command":"ls *"}`},
+	}
+	for _, tt := range tests {
+		_, err := tt.tool.Call(context.Background(), json.RawMessage(tt.args))
+		if tt.wantRun && err != nil || !tt.wantRun && !errors.Is(err, ErrDenied) {
+			t.Errorf("%s: got error %v, want run %v", tt.args, err, tt.wantRun)
+		}
+	}
+	if len(asked) != 13 {
+		t.Errorf("asked about %v, want the 13 denied calls", asked)
+	}
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// fetchURLArgs are the arguments of fetch_url
+type fetchURLArgs struct {
+	URL string `json:"url"`
+}
+
+// fetchURLResult is the result of fetch_url
+type fetchURLResult struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content"`
+	Truncated   bool   `json:"truncated,omitempty"`
+}
+
+// newFetchURL creates the tool fetching a web page from the allowed hosts
+func newFetchURL(opts BuiltinOptions) Tool {
+	// Redirects must stay on the allowed hosts too
+	httpClient := *opts.HTTPClient
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return checkHost(req.URL, opts.AllowedHosts)
+	}
+
+	return NewFunc(FetchURLName,
+		"Fetches a web page or a file over HTTP. Only the allowed hosts can be fetched: "+strings.Join(opts.AllowedHosts, ", "),
+		`{
+			"type": "object",
+			"properties": {
+				"url": {"type": "string", "description": "Absolute http or https URL"}
+			},
+			"required": ["url"]
+		}`,
+		func(ctx context.Context, args fetchURLArgs) (any, error) {
+			u, err := url.Parse(args.URL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse URL: %w", err)
+			}
+			if u.Scheme != "http" && u.Scheme != "https" {
+				return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+			}
+			if err := checkHost(u, opts.AllowedHosts); err != nil {
+				return nil, err
+			}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build request: %w", err)
+			}
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch URL: %w", err)
+			}
+			defer resp.Body.Close()
+
+			data, err := io.ReadAll(io.LimitReader(resp.Body, int64(opts.MaxOutput)+1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read response body: %w", err)
+			}
+			content, truncated := truncate(string(data), opts.MaxOutput)
+			return fetchURLResult{
+				URL:         resp.Request.URL.String(),
+				Status:      resp.StatusCode,
+				ContentType: resp.Header.Get("Content-Type"),
+				Content:     content,
+				Truncated:   truncated,
+			}, nil
+		})
+}
+
+// checkHost rejects URLs of hosts missing from the allowlist, a listed host allows its subdomains
+func checkHost(u *url.URL, allowed []string) error {
+	host := strings.ToLower(u.Hostname())
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not allowed", host)
+}
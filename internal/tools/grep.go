@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxGrepMatches limits the number of matching lines returned by grep
+	maxGrepMatches = 100
+	// maxGrepFileSize skips large files, they are hardly source code
+	maxGrepFileSize = 1024 * 1024
+)
+
+// grepArgs are the arguments of grep
+type grepArgs struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path"`
+	Glob    string `json:"glob"`
+}
+
+// grepMatch is a matching line
+type grepMatch struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// grepResult is the result of grep
+type grepResult struct {
+	Matches   []grepMatch `json:"matches"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// newGrep creates the tool searching the files of a directory under the root for a regular expression
+func newGrep(opts BuiltinOptions) Tool {
+	return NewFunc(GrepName,
+		"Searches the text files of a project directory for lines matching a regular expression",
+		`{
+			"type": "object",
+			"properties": {
+				"pattern": {"type": "string", "description": "Regular expression in Go syntax"},
+				"path": {"type": "string", "description": "Directory relative to the project root, the root if empty"},
+				"glob": {"type": "string", "description": "File name pattern, e.g. *.go"}
+			},
+			"required": ["pattern"]
+		}`,
+		func(ctx context.Context, args grepArgs) (any, error) {
+			re, err := regexp.Compile(args.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile pattern: %w", err)
+			}
+			if args.Glob != "" {
+				if _, err := filepath.Match(args.Glob, ""); err != nil {
+					return nil, fmt.Errorf("failed to parse glob: %w", err)
+				}
+			}
+			dir, err := resolvePath(opts.Root, args.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			result := grepResult{Matches: []grepMatch{}}
+			size := 0
+			err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					// Unreadable entries below the directory are skipped
+					if path == dir {
+						return err
+					}
+					return nil
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if d.IsDir() {
+					// Skip version control and other hidden directories
+					if path != dir && strings.HasPrefix(d.Name(), ".") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !d.Type().IsRegular() {
+					return nil
+				}
+				if args.Glob != "" {
+					if ok, _ := filepath.Match(args.Glob, d.Name()); !ok {
+						return nil
+					}
+				}
+
+				for _, m := range grepFile(path, re) {
+					m.File = relPath(opts.Root, path)
+					size += len(m.File) + len(m.Text)
+					if len(result.Matches) == maxGrepMatches || size > opts.MaxOutput {
+						result.Truncated = true
+						return filepath.SkipAll
+					}
+					result.Matches = append(result.Matches, m)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to search %s: %w", args.Path, err)
+			}
+			return result, nil
+		})
+}
+
+// grepFile returns the matching lines of the file, unreadable, binary and large files are skipped
+func grepFile(path string, re *regexp.Regexp) []grepMatch {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxGrepFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return nil
+	}
+
+	var matches []grepMatch
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxGrepFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		if text := scanner.Text(); re.MatchString(text) {
+			matches = append(matches, grepMatch{Line: line, Text: text})
+		}
+	}
+	return matches
+}
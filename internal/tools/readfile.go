@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readFileArgs are the arguments of read_file
+type readFileArgs struct {
+	Path string `json:"path"`
+}
+
+// readFileResult is the result of read_file
+type readFileResult struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// newReadFile creates the tool reading a text file under the root
+func newReadFile(opts BuiltinOptions) Tool {
+	return NewFunc(ReadFileName,
+		"Reads a text file of the project. Long files are truncated",
+		`{
+			"type": "object",
+			"properties": {
+				"path": {"type": "string", "description": "File path relative to the project root"}
+			},
+			"required": ["path"]
+		}`,
+		func(_ context.Context, args readFileArgs) (any, error) {
+			path, err := resolvePath(opts.Root, args.Path)
+			if err != nil {
+				return nil, err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+
+			// Read a byte more than allowed to tell whether the file is truncated
+			data, err := io.ReadAll(io.LimitReader(f, int64(opts.MaxOutput)+1))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			if bytes.IndexByte(data, 0) >= 0 {
+				return nil, fmt.Errorf("file %s is binary", args.Path)
+			}
+			content, truncated := truncate(string(data), opts.MaxOutput)
+			return readFileResult{Path: relPath(opts.Root, path), Content: content, Truncated: truncated}, nil
+		})
+}
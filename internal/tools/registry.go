@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/gennadis/gigachatui/gigachat"
+)
+
+// Registry is a set of tools by name, safe for concurrent use.
+// The zero Registry is empty and ready to use
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	names []string // registration order
+}
+
+// NewRegistry creates a new Registry with the tools
+func NewRegistry(tools ...Tool) (*Registry, error) {
+	r := &Registry{}
+	if err := r.Register(tools...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Register adds the tools to the registry, their names must be unique
+func (r *Registry) Register(tools ...Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tools == nil {
+		r.tools = make(map[string]Tool)
+	}
+	for _, t := range tools {
+		f := t.Function()
+		if f.Name == "" {
+			return errors.New("tool name is required")
+		}
+		if _, ok := r.tools[f.Name]; ok {
+			return fmt.Errorf("tool %s is already registered", f.Name)
+		}
+		if !json.Valid(f.Parameters) {
+			return fmt.Errorf("tool %s parameters are not a valid JSON schema", f.Name)
+		}
+		r.tools[f.Name] = t
+		r.names = append(r.names, f.Name)
+	}
+	return nil
+}
+
+// Len returns the number of registered tools
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.names)
+}
+
+// Functions describes the registered tools to the model, in registration order
+func (r *Registry) Functions() []gigachat.Function {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	functions := make([]gigachat.Function, 0, len(r.names))
+	for _, name := range r.names {
+		functions = append(functions, r.tools[name].Function())
+	}
+	return functions
+}
+
+// Call runs the named tool and returns its result as a JSON object.
+// A failed call returns the error too, its result describes the error to the model
+func (r *Registry) Call(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	r.mu.RLock()
+	t, ok := r.tools[name]
+	r.mu.RUnlock()
+
+	var (
+		result any
+		err    error
+	)
+	if ok {
+		result, err = t.Call(ctx, args)
+	} else {
+		err = fmt.Errorf("unknown tool %s", name)
+	}
+	if err != nil {
+		return errorResult(err), err
+	}
+
+	content, err := resultObject(result)
+	if err != nil {
+		return errorResult(err), err
+	}
+
+	slog.Debug("tool called",
+		slog.String("name", name),
+		slog.Int("result_size", len(content)),
+	)
+	return content, nil
+}
+
+// resultObject marshals the result, values other than JSON objects are wrapped into {"result": value}
+// since the model accepts only objects as function results
+func resultObject(result any) (string, error) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tool result: %w", err)
+	}
+	var object map[string]json.RawMessage
+	if json.Unmarshal(b, &object) == nil && object != nil {
+		return string(b), nil
+	}
+	b, err = json.Marshal(map[string]json.RawMessage{"result": b})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tool result: %w", err)
+	}
+	return string(b), nil
+}
+
+// errorResult describes the error of the call to the model
+func errorResult(err error) string {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(b)
+}
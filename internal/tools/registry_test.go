@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	echo := NewFunc("echo", "Echoes the text", `{"type":"object","properties":{"text":{"type":"string"}}}`,
+		func(_ context.Context, args struct{ Text string }) (any, error) {
+			return args.Text, nil
+		})
+	fail := NewFunc("fail", "Always fails", `{"type":"object"}`,
+		func(context.Context, json.RawMessage) (any, error) {
+			return nil, errors.New("boom")
+		})
+	r, err := NewRegistry(echo, fail)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	if err := r.Register(echo); err == nil {
+		t.Error("registered a duplicate tool")
+	}
+	if err := r.Register(NewFunc("bad", "", "{", func(context.Context, any) (any, error) { return nil, nil })); err == nil {
+		t.Error("registered a tool with invalid parameters")
+	}
+	if functions := r.Functions(); len(functions) != 2 || functions[0].Name != "echo" || functions[1].Name != "fail" {
+		t.Errorf("got functions %+v", functions)
+	}
+
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{name: "echo", args: `{"text":"hi"}`, want: `{"result":"hi"}`},
+		{name: "echo", args: `{"text":1}`, wantErr: true},
+		{name: "fail", want: `{"error":"boom"}`, wantErr: true},
+		{name: "missing", want: `{"error":"unknown tool missing"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := r.Call(context.Background(), tt.name, json.RawMessage(tt.args))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%s) error %v, want error %v", tt.name, tt.args, err, tt.wantErr)
+		}
+		if !json.Valid([]byte(got)) || tt.want != "" && got != tt.want {
+			t.Errorf("%s(%s) = %s, want %s", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// runShellArgs are the arguments of run_shell
+type runShellArgs struct {
+	Command string `json:"command"`
+}
+
+// runShellResult is the result of run_shell
+type runShellResult struct {
+	ExitCode  int    `json:"exit_code"`
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// newRunShell creates the tool running a shell command in the root
+func newRunShell(opts BuiltinOptions) Tool {
+	return NewFunc(RunShellName,
+		"Runs a shell command in the project root and returns its combined output and exit code",
+		`{
+			"type": "object",
+			"properties": {
+				"command": {"type": "string", "description": "Command line run by sh -c"}
+			},
+			"required": ["command"]
+		}`,
+		func(ctx context.Context, args runShellArgs) (any, error) {
+			if args.Command == "" {
+				return nil, errors.New("command is required")
+			}
+			ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+
+			cmd := exec.CommandContext(ctx, "sh", "-c", args.Command)
+			cmd.Dir = opts.Root
+			out, err := cmd.CombinedOutput()
+
+			// A failed command is a result too, the model sees its output and exit code
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("failed to run command: %w", err)
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("failed to run command: %w", err)
+			}
+			output, truncated := truncate(string(out), opts.MaxOutput)
+			return runShellResult{ExitCode: cmd.ProcessState.ExitCode(), Output: output, Truncated: truncated}, nil
+		})
+}
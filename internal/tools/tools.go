@@ -0,0 +1,56 @@
+// Package tools provides the functions the assistant may call during a conversation.
+//
+// Tools declare their arguments as a JSON schema, the registry describes them to the model
+// and runs the calls it makes. Results are JSON objects fed back to the model as function messages
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gennadis/gigachatui/gigachat"
+)
+
+// Tool is a function the assistant may call
+type Tool interface {
+	// Function describes the tool to the model, its parameters are a JSON schema
+	Function() gigachat.Function
+	// Call runs the tool with the JSON object of the call arguments and returns the result
+	Call(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// funcTool is a Tool made of a Go function with typed arguments
+type funcTool[A any] struct {
+	function gigachat.Function
+	run      func(context.Context, A) (any, error)
+}
+
+// NewFunc creates a Tool running the function with the call arguments decoded into A.
+// The parameters are the JSON schema of A
+func NewFunc[A any](name, description, parameters string, run func(ctx context.Context, args A) (any, error)) Tool {
+	return &funcTool[A]{
+		function: gigachat.Function{
+			Name:        name,
+			Description: description,
+			Parameters:  json.RawMessage(parameters),
+		},
+		run: run,
+	}
+}
+
+// Function implements the Tool interface
+func (t *funcTool[A]) Function() gigachat.Function {
+	return t.function
+}
+
+// Call implements the Tool interface
+func (t *funcTool[A]) Call(ctx context.Context, args json.RawMessage) (any, error) {
+	var a A
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, fmt.Errorf("failed to decode arguments: %w", err)
+		}
+	}
+	return t.run(ctx, a)
+}
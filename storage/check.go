@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recoveredSessionName is the name prefix of sessions restored for orphaned messages
+const recoveredSessionName = "recovered"
+
+// Orphan describes messages referencing a session that does not exist
+type Orphan struct {
+	SessionID string `db:"session_id"`
+	Messages  int    `db:"messages"`
+}
+
+// FindOrphans returns messages grouped by missing sessions they reference
+func FindOrphans(db *sqlx.DB) ([]Orphan, error) {
+	var orphans []Orphan
+	findQuery := `
+	SELECT session_id, COUNT(*) AS messages
+	FROM messages
+	WHERE session_id NOT IN (SELECT id FROM sessions)
+	GROUP BY session_id
+	ORDER BY session_id
+	`
+	if err := db.Select(&orphans, findQuery); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned messages: %w", err)
+	}
+
+	slog.Debug("found orphaned messages",
+		slog.Int("sessions", len(orphans)),
+	)
+	return orphans, nil
+}
+
+// RestoreOrphans restores a placeholder session for every orphan, keeping its messages.
+// The restored session is dated by its earliest message
+func RestoreOrphans(db *sqlx.DB, orphans []Orphan) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin restore orphans transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	insertQuery := tx.Rebind(`
+	INSERT INTO sessions (id, name, timestamp)
+	SELECT ?, ?, MIN(timestamp) FROM messages WHERE session_id = ?
+	`)
+	for _, o := range orphans {
+		name := fmt.Sprintf("%s %s", recoveredSessionName, o.SessionID)
+		if _, err := tx.Exec(insertQuery, o.SessionID, name, o.SessionID); err != nil {
+			return fmt.Errorf("failed to restore session %s: %w", o.SessionID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit restore orphans transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrphans deletes all messages referencing missing sessions and returns their count
+func DeleteOrphans(db *sqlx.DB) (int64, error) {
+	res, err := db.Exec("DELETE FROM messages WHERE session_id NOT IN (SELECT id FROM sessions)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphaned messages: %w", err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted orphaned messages: %w", err)
+	}
+	return deleted, nil
+}
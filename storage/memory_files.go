@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// MemoryFiles is an in-memory storage for uploaded files.
+// It is used in tests and for ephemeral runs without history
+type MemoryFiles struct {
+	mu    sync.RWMutex
+	files map[string]chat.File
+}
+
+// NewMemoryFiles creates a new MemoryFiles storage
+func NewMemoryFiles() *MemoryFiles {
+	return &MemoryFiles{files: make(map[string]chat.File)}
+}
+
+// Read returns all uploaded files, newest first
+func (f *MemoryFiles) Read() ([]chat.File, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	files := make([]chat.File, 0, len(f.files))
+	for _, file := range f.files {
+		files = append(files, file)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Timestamp.After(files[j].Timestamp) })
+
+	slog.Debug("read files",
+		slog.Int("count", len(files)),
+	)
+	return files, nil
+}
+
+// Write writes the uploaded file to the storage, replacing the file with the same id
+func (f *MemoryFiles) Write(file chat.File) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if file.Timestamp.IsZero() {
+		file.Timestamp = time.Now()
+	}
+	f.files[file.ID] = file
+
+	slog.Debug("file added to files",
+		slog.String("id", file.ID),
+		slog.String("filename", file.Filename),
+		slog.Int64("bytes", file.Bytes),
+	)
+	return nil
+}
+
+// Delete deletes the given file by id
+func (f *MemoryFiles) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.files[id]; !ok {
+		return fmt.Errorf("failed to get file for id %s: %w", id, ErrNotFound)
+	}
+	delete(f.files, id)
+
+	slog.Debug("file deleted from files",
+		slog.String("id", id),
+	)
+	return nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// MemoryMessages is an in-memory storage for messages.
+// It is used in tests and for ephemeral runs without history
+type MemoryMessages struct {
+	mu       sync.RWMutex
+	messages []chat.Message
+	sessions *MemorySessions // set by NewMemorySessions to keep session heads valid
+}
+
+// NewMemoryMessages creates a new MemoryMessages storage
+func NewMemoryMessages() *MemoryMessages {
+	return &MemoryMessages{}
+}
+
+// Read returns all messages
+func (m *MemoryMessages) Read() ([]chat.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	messages := make([]chat.Message, len(m.messages))
+	copy(messages, m.messages)
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].SessionID < messages[j].SessionID })
+
+	slog.Debug("read messages",
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadBySessionID returns messages for a specific session_id
+func (m *MemoryMessages) ReadBySessionID(sessionID string) ([]chat.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var messages []chat.Message
+	for _, message := range m.messages {
+		if message.SessionID == sessionID {
+			messages = append(messages, message)
+		}
+	}
+
+	slog.Debug("read messages by session_id",
+		slog.String("session_id", sessionID),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadStarred returns all starred messages
+func (m *MemoryMessages) ReadStarred() ([]chat.Message, error) {
+	all, err := m.Read()
+	if err != nil {
+		return nil, err
+	}
+	messages := slices.DeleteFunc(all, func(message chat.Message) bool { return !message.Starred })
+
+	slog.Debug("read starred messages",
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadBranch returns the branch ending at the given message, walking parents up to the root.
+// Messages are ordered from the root to the given message
+func (m *MemoryMessages) ReadBranch(messageID string) ([]chat.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var messages []chat.Message
+	for id := messageID; id != ""; {
+		i := m.indexOf(id)
+		if i < 0 {
+			break
+		}
+		messages = append(messages, m.messages[i])
+		id = m.messages[i].ParentID
+	}
+	slices.Reverse(messages)
+
+	slog.Debug("read messages branch",
+		slog.String("id", messageID),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// Search returns messages containing all terms of the query, newest first
+func (m *MemoryMessages) Search(query string, limit int) ([]chat.Message, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var messages []chat.Message
+	for i := len(m.messages) - 1; i >= 0 && len(messages) < limit; i-- {
+		if containsAll(strings.ToLower(m.messages[i].Content), terms) {
+			messages = append(messages, m.messages[i])
+		}
+	}
+
+	slog.Debug("search messages",
+		slog.String("query", query),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// Write writes new message to the storage
+func (m *MemoryMessages) Write(message chat.Message) error {
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Ignore the message if it already exists
+	if m.indexOf(message.ID) >= 0 {
+		return nil
+	}
+
+	// Assign the next sequence number of the session, messages are kept in write order
+	// so the sequence numbers of a session are always increasing
+	message.Seq = 1
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].SessionID == message.SessionID {
+			message.Seq = m.messages[i].Seq + 1
+			break
+		}
+	}
+	m.messages = append(m.messages, message)
+
+	slog.Debug("message added to messages",
+		slog.String("id", message.ID),
+		slog.String("session_id", message.SessionID),
+		slog.String("parent_id", message.ParentID),
+		slog.String("content", message.Content),
+		slog.String("role", string(message.Role)),
+		slog.Time("timestamp", message.Timestamp),
+	)
+	return nil
+}
+
+// SetStarred stars or unstars the message
+func (m *MemoryMessages) SetStarred(id string, starred bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.indexOf(id)
+	if i < 0 {
+		return fmt.Errorf("failed to get message for id %s: %w", id, ErrNotFound)
+	}
+	m.messages[i].Starred = starred
+
+	slog.Debug("message starred",
+		slog.String("id", id),
+		slog.Bool("starred", starred),
+	)
+	return nil
+}
+
+// Delete deletes the given message by id from the storage.
+// Its children are attached to its parent, so the branches stay connected
+func (m *MemoryMessages) Delete(id string) error {
+	m.mu.Lock()
+	i := m.indexOf(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("failed to get message for id %s: %w", id, ErrNotFound)
+	}
+	message := m.messages[i]
+	m.messages = append(m.messages[:i], m.messages[i+1:]...)
+	for j := range m.messages {
+		if m.messages[j].ParentID == id {
+			m.messages[j].ParentID = message.ParentID
+		}
+	}
+	m.mu.Unlock()
+
+	// The sessions lock is taken after releasing the messages one,
+	// MemorySessions.Delete locks them in the opposite order
+	if m.sessions != nil {
+		m.sessions.moveHead(id, message.ParentID)
+	}
+
+	slog.Debug("message deleted from messages",
+		slog.String("id", message.ID),
+		slog.String("session_id", message.SessionID),
+		slog.Time("timestamp", message.Timestamp),
+	)
+	return nil
+}
+
+// deleteBySessionID deletes all messages of the session and returns their count
+func (m *MemoryMessages) deleteBySessionID(sessionID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.messages[:0]
+	for _, message := range m.messages {
+		if message.SessionID != sessionID {
+			kept = append(kept, message)
+		}
+	}
+	deleted := len(m.messages) - len(kept)
+	clear(m.messages[len(kept):])
+	m.messages = kept
+	return deleted
+}
+
+// indexOf returns the index of the message with the given id or -1
+func (m *MemoryMessages) indexOf(id string) int {
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// containsAll reports whether s contains all of the terms
+func containsAll(s string, terms []string) bool {
+	for _, t := range terms {
+		if !strings.Contains(s, t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+)
+
+// MemorySessions is an in-memory storage for sessions.
+// It is used in tests and for ephemeral runs without history
+type MemorySessions struct {
+	mu       sync.RWMutex
+	sessions map[string]chat.Session
+	messages *MemoryMessages
+}
+
+// NewMemorySessions creates a new MemorySessions storage.
+// Deleting a session also deletes its messages from the given messages storage
+func NewMemorySessions(messages *MemoryMessages) *MemorySessions {
+	s := &MemorySessions{
+		sessions: make(map[string]chat.Session),
+		messages: messages,
+	}
+	messages.sessions = s
+	return s
+}
+
+// Read returns all sessions
+func (s *MemorySessions) Read() ([]chat.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]chat.Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Timestamp.After(sessions[j].Timestamp) })
+
+	slog.Debug("read sessions",
+		slog.Int("count", len(sessions)),
+	)
+	return sessions, nil
+}
+
+// Get returns the session by id
+func (s *MemorySessions) Get(id string) (chat.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return chat.Session{}, fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+	return session, nil
+}
+
+// SetHead makes the message the head of the session's active branch
+func (s *MemorySessions) SetHead(id, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+	session.HeadID = messageID
+	s.sessions[id] = session
+
+	slog.Debug("session head moved",
+		slog.String("id", id),
+		slog.String("head_id", messageID),
+	)
+	return nil
+}
+
+// Rename changes the name of the session
+func (s *MemorySessions) Rename(id, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+	session.Name = name
+	s.sessions[id] = session
+
+	slog.Debug("session renamed",
+		slog.String("id", id),
+		slog.String("name", name),
+	)
+	return nil
+}
+
+// Write writes new session to the storage
+func (s *MemorySessions) Write(session chat.Session) error {
+	if session.Timestamp.IsZero() {
+		session.Timestamp = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Ignore the session if it already exists
+	if _, ok := s.sessions[session.ID]; ok {
+		return nil
+	}
+	s.sessions[session.ID] = session
+
+	slog.Debug("session added to sessions",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Time("timestamp", session.Timestamp),
+	)
+	return nil
+}
+
+// Import writes the session with its messages, the head is set only for a new session
+func (s *MemorySessions) Import(session chat.Session, messages []chat.Message) error {
+	if session.Timestamp.IsZero() {
+		session.Timestamp = time.Now()
+	}
+
+	// The sessions lock is held while the messages are written, no one sees the session half imported
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.sessions[session.ID]
+	for _, message := range messages {
+		if err := s.messages.Write(message); err != nil {
+			return err
+		}
+	}
+	if !exists {
+		s.sessions[session.ID] = session
+	}
+
+	slog.Debug("session imported",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Bool("created", !exists),
+		slog.Int("messages", len(messages)),
+	)
+	return nil
+}
+
+// Delete deletes the given session by id together with its messages
+func (s *MemorySessions) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+	deletedMessages := s.messages.deleteBySessionID(id)
+	delete(s.sessions, id)
+
+	slog.Debug("session deleted from sessions",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Int("deleted_messages", deletedMessages),
+	)
+	return nil
+}
+
+// moveHead moves the head of every session pointing at the message to the new head
+func (s *MemorySessions) moveHead(messageID, headID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.HeadID == messageID {
+			session.HeadID = headID
+			s.sessions[id] = session
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationsFS embed.FS
+
+// migrationsDirs maps database driver names to their migrations directories.
+// Every dialect must provide the same set of migration versions
+var migrationsDirs = map[string]string{
+	driverSqlite:   "migrations/sqlite",
+	driverPostgres: "migrations/postgres",
+}
+
+// Migration represents a single forward schema migration
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// LoadMigrations returns all embedded migrations for the given driver ordered by version.
+// Migration files are named as <version>_<name>.sql, e.g. 0001_init.sql
+func LoadMigrations(driver string) ([]Migration, error) {
+	dir, ok := migrationsDirs[driver]
+	if !ok {
+		return nil, fmt.Errorf("no migrations for database driver %s", driver)
+	}
+
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	for _, e := range entries {
+		versionStr, name, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in file name %s: %w", e.Name(), err)
+		}
+		body, err := migrationsFS.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion returns the latest applied migration version, 0 for an empty database.
+// It only reads the database, a missing schema_version table means no migrations are applied
+func SchemaVersion(db *sqlx.DB) (int, error) {
+	exists, err := schemaVersionTableExists(db)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	if err := db.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM schema_version"); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate applies all pending migrations and returns them.
+// If dryRun is true, pending migrations are returned without being applied
+func Migrate(db *sqlx.DB, dryRun bool) ([]Migration, error) {
+	migrations, err := LoadMigrations(db.DriverName())
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	if err := createSchemaVersionTable(db); err != nil {
+		return nil, err
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(db, m); err != nil {
+			return nil, err
+		}
+		slog.Debug("migration applied",
+			slog.Int("version", m.Version),
+			slog.String("name", m.Name),
+		)
+	}
+	return pending, nil
+}
+
+// applyMigration runs a single migration and records its version in one transaction
+func applyMigration(db *sqlx.DB, m Migration) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d transaction: %w", m.Version, err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(tx.Rebind("INSERT INTO schema_version (version, name) VALUES (?, ?)"), m.Version, m.Name); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// schemaVersionTableExists reports whether the table that tracks applied migrations exists
+func schemaVersionTableExists(db *sqlx.DB) (bool, error) {
+	var query string
+	switch db.DriverName() {
+	case driverSqlite:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'"
+	case driverPostgres:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_version'"
+	default:
+		return false, fmt.Errorf("unsupported database driver %s", db.DriverName())
+	}
+
+	var count int
+	if err := db.Get(&count, query); err != nil {
+		return false, fmt.Errorf("failed to check schema_version table: %w", err)
+	}
+	return count > 0, nil
+}
+
+// createSchemaVersionTable creates the table that tracks applied migrations
+func createSchemaVersionTable(db *sqlx.DB) error {
+	createSchemaVersionTable := `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+	`
+	if _, err := db.Exec(createSchemaVersionTable); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	return nil
+}
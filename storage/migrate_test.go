@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// baselineSchema is the schema of databases created before the migrations, without schema_version
+const baselineSchema = `
+CREATE TABLE sessions (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE messages (
+	id TEXT PRIMARY KEY,
+	session_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	role TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (session_id) REFERENCES sessions(id)
+);
+`
+
+// newBaselineDB creates a sqlite database at the baseline schema in the test directory
+func newBaselineDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := NewSqliteDB(filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatalf("failed to create baseline schema: %v", err)
+	}
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := newBaselineDB(t)
+	migrations, err := LoadMigrations(driverSqlite)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	// A dry run lists the migrations without touching the database
+	pending, err := Migrate(db, true)
+	if err != nil || len(pending) != len(migrations) {
+		t.Fatalf("got %d pending migrations, %v, want %d", len(pending), err, len(migrations))
+	}
+	if exists, err := schemaVersionTableExists(db); err != nil || exists {
+		t.Errorf("dry run created the schema_version table, %v", err)
+	}
+	if version, err := SchemaVersion(db); err != nil || version != 0 {
+		t.Errorf("got version %d, %v after dry run, want 0", version, err)
+	}
+
+	applied, err := Migrate(db, false)
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("got %d applied migrations, %v, want %d", len(applied), err, len(migrations))
+	}
+	if version, err := SchemaVersion(db); err != nil || version != latest {
+		t.Errorf("got version %d, %v, want %d", version, err, latest)
+	}
+
+	// Migrating an up to date database does nothing
+	applied, err = Migrate(db, false)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("got %d migrations applied again, %v", len(applied), err)
+	}
+	if version, err := SchemaVersion(db); err != nil || version != latest {
+		t.Errorf("got version %d, %v after second run, want %d", version, err, latest)
+	}
+}
+
+// migrateTo applies the migrations up to the version
+func migrateTo(t *testing.T, db *sqlx.DB, version int) {
+	t.Helper()
+	migrations, err := LoadMigrations(db.DriverName())
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := createSchemaVersionTable(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version <= version {
+			if err := applyMigration(db, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// insertOldMessages inserts the messages of two sessions under the schema before message sequence numbers.
+// The messages of the first session are inserted out of order, two of them at the same time
+func insertOldMessages(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for _, id := range []string{"first", "second"} {
+		if _, err := db.Exec("INSERT INTO sessions (id, name, timestamp) VALUES (?, ?, ?)", id, id, start); err != nil {
+			t.Fatalf("failed to insert session: %v", err)
+		}
+	}
+	messages := []struct {
+		id, sessionID, content string
+		at                     time.Duration
+	}{
+		{id: "m-b", sessionID: "first", content: "b", at: 2 * time.Second},
+		{id: "m-a", sessionID: "first", content: "a", at: time.Second},
+		{id: "m-0", sessionID: "first", content: "c", at: 2 * time.Second},
+		{id: "m-x", sessionID: "second", content: "x", at: 3 * time.Second},
+		{id: "m-d", sessionID: "first", content: "d", at: 4 * time.Second},
+	}
+	for _, m := range messages {
+		_, err := db.Exec("INSERT INTO messages (id, session_id, content, role, timestamp) VALUES (?, ?, ?, 'user', ?)",
+			m.id, m.sessionID, m.content, start.Add(m.at))
+		if err != nil {
+			t.Fatalf("failed to insert message: %v", err)
+		}
+	}
+}
+
+func TestMigrateMessagesSeq(t *testing.T) {
+	db := newBaselineDB(t)
+	migrateTo(t, db, 2)
+	insertOldMessages(t, db)
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// Messages are numbered per session by time, messages at the same time keep their insertion order
+	messages := NewSqliteMessages(db)
+	for sessionID, want := range map[string]string{"first": "a1 b2 c3 d4", "second": "x1"} {
+		stored, err := messages.ReadBySessionID(sessionID)
+		if err != nil {
+			t.Fatalf("failed to read messages: %v", err)
+		}
+		var got []string
+		for _, m := range stored {
+			got = append(got, fmt.Sprintf("%s%d", m.Content, m.Seq))
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("got %s messages %v, want %s", sessionID, got, want)
+		}
+	}
+}
+
+func TestMigrateMessageBranches(t *testing.T) {
+	db := newBaselineDB(t)
+	migrateTo(t, db, 2)
+	insertOldMessages(t, db)
+	if _, err := db.Exec("INSERT INTO sessions (id, name) VALUES ('empty', 'empty')"); err != nil {
+		t.Fatalf("failed to insert session: %v", err)
+	}
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// Every message replies to the one before it and the last message is the head
+	stored, err := NewSqliteMessages(db).Read()
+	if err != nil {
+		t.Fatalf("failed to read messages: %v", err)
+	}
+	parents := make(map[string]string)
+	for _, m := range stored {
+		parents[m.ID] = m.ParentID
+	}
+	want := map[string]string{"m-a": "", "m-b": "m-a", "m-0": "m-b", "m-d": "m-0", "m-x": ""}
+	if fmt.Sprint(parents) != fmt.Sprint(want) {
+		t.Errorf("got parents %v, want %v", parents, want)
+	}
+
+	sessions := NewSqliteSessions(db)
+	for id, head := range map[string]string{"first": "m-d", "second": "m-x", "empty": ""} {
+		if session, err := sessions.Get(id); err != nil || session.HeadID != head {
+			t.Errorf("got session %+v, %v, want head %q", session, err, head)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+ALTER TABLE messages
+	ADD COLUMN content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX messages_content_tsv_idx ON messages USING GIN (content_tsv);
@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN seq BIGINT NOT NULL DEFAULT 0;
+
+UPDATE messages SET seq = numbered.n
+FROM (
+	SELECT id, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp, id) AS n
+	FROM messages
+) AS numbered
+WHERE messages.id = numbered.id;
+
+CREATE UNIQUE INDEX messages_session_seq_idx ON messages (session_id, seq);
@@ -0,0 +1,17 @@
+ALTER TABLE messages ADD COLUMN parent_id TEXT;
+
+UPDATE messages SET parent_id = (
+	SELECT p.id FROM messages p
+	WHERE p.session_id = messages.session_id AND p.seq = messages.seq - 1
+);
+
+CREATE INDEX messages_parent_idx ON messages (parent_id);
+
+ALTER TABLE sessions ADD COLUMN head_id TEXT;
+
+UPDATE sessions SET head_id = (
+	SELECT m.id FROM messages m
+	WHERE m.session_id = sessions.id
+	ORDER BY m.seq DESC
+	LIMIT 1
+);
@@ -0,0 +1,3 @@
+ALTER TABLE messages ADD COLUMN starred BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX messages_starred_idx ON messages (starred);
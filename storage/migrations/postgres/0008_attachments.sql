@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN attachments TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS files (
+	id TEXT PRIMARY KEY,
+	filename TEXT NOT NULL,
+	path TEXT NOT NULL DEFAULT '',
+	purpose TEXT NOT NULL,
+	bytes BIGINT NOT NULL DEFAULT 0,
+	timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,20 @@
+CREATE VIRTUAL TABLE messages_fts USING fts5(
+	content,
+	content='messages',
+	content_rowid='rowid'
+);
+
+INSERT INTO messages_fts (rowid, content) SELECT rowid, content FROM messages;
+
+CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO messages_fts (rowid, content) VALUES (new.rowid, new.content);
+END;
+
+CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+END;
+
+CREATE TRIGGER messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+	INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+	INSERT INTO messages_fts (rowid, content) VALUES (new.rowid, new.content);
+END;
@@ -0,0 +1,10 @@
+ALTER TABLE messages ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+UPDATE messages SET seq = (
+	SELECT n FROM (
+		SELECT rowid AS r, ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp, rowid) AS n
+		FROM messages
+	) WHERE r = messages.rowid
+);
+
+CREATE UNIQUE INDEX messages_session_seq_idx ON messages (session_id, seq);
@@ -0,0 +1,4 @@
+ALTER TABLE messages ADD COLUMN model TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN prompt_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN completion_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN total_tokens INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE messages ADD COLUMN function_call TEXT;
+ALTER TABLE messages ADD COLUMN function_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN functions_state_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN inlined_files TEXT NOT NULL DEFAULT '';
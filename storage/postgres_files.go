@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresFiles is a postgres storage for uploaded files
+type PostgresFiles struct {
+	db *sqlx.DB
+}
+
+// NewPostgresFiles creates a new PostgresFiles storage.
+// The files table is created by the schema migrations, see Migrate
+func NewPostgresFiles(db *sqlx.DB) *PostgresFiles {
+	return &PostgresFiles{db: db}
+}
+
+// Read returns all uploaded files, newest first
+func (f *PostgresFiles) Read() ([]chat.File, error) {
+	var files []chat.File
+	if err := f.db.Select(&files, "SELECT "+fileColumns+" FROM files ORDER BY timestamp DESC"); err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+
+	slog.Debug("read files",
+		slog.Int("count", len(files)),
+	)
+	return files, nil
+}
+
+// Write writes the uploaded file to the storage, replacing the file with the same id
+func (f *PostgresFiles) Write(file chat.File) error {
+	if file.Timestamp.IsZero() {
+		file.Timestamp = time.Now()
+	}
+	upsertQuery := `
+	INSERT INTO files (id, filename, path, purpose, bytes, timestamp) VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (id) DO UPDATE SET filename = excluded.filename, path = excluded.path,
+		purpose = excluded.purpose, bytes = excluded.bytes, timestamp = excluded.timestamp
+	`
+	if _, err := f.db.Exec(upsertQuery, file.ID, file.Filename, file.Path, file.Purpose, file.Bytes, file.Timestamp); err != nil {
+		return fmt.Errorf("failed to insert file %s: %w", file.ID, err)
+	}
+
+	slog.Debug("file added to files",
+		slog.String("id", file.ID),
+		slog.String("filename", file.Filename),
+		slog.Int64("bytes", file.Bytes),
+	)
+	return nil
+}
+
+// Delete deletes the given file by id
+func (f *PostgresFiles) Delete(id string) error {
+	res, err := f.db.Exec("DELETE FROM files WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete file by id %s: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get file for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("file deleted from files",
+		slog.String("id", id),
+	)
+	return nil
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresMessages is a postgres storage for messages
+type PostgresMessages struct {
+	db *sqlx.DB
+}
+
+// NewPostgresMessages creates a new PostgresMessages storage.
+// The messages table is created by the schema migrations, see Migrate
+func NewPostgresMessages(db *sqlx.DB) *PostgresMessages {
+	return &PostgresMessages{db: db}
+}
+
+// Read returns all messages
+func (m *PostgresMessages) Read() ([]chat.Message, error) {
+	var messages []chat.Message
+	err := m.db.Select(&messages, "SELECT "+messageColumns+" FROM messages ORDER BY session_id, seq ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	slog.Debug("read messages",
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadBySessionID returns messages for a specific session_id
+func (m *PostgresMessages) ReadBySessionID(sessionID string) ([]chat.Message, error) {
+	var messages []chat.Message
+	err := m.db.Select(&messages, "SELECT "+messageColumns+" FROM messages WHERE session_id = $1 ORDER BY seq ASC", sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages for session_id %s: %w", sessionID, err)
+	}
+
+	slog.Debug("read messages by session_id",
+		slog.String("session_id", sessionID),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadStarred returns all starred messages
+func (m *PostgresMessages) ReadStarred() ([]chat.Message, error) {
+	var messages []chat.Message
+	err := m.db.Select(&messages, "SELECT "+messageColumns+" FROM messages WHERE starred = TRUE ORDER BY session_id, seq ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get starred messages: %w", err)
+	}
+
+	slog.Debug("read starred messages",
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadBranch returns the branch ending at the given message, walking parents up to the root.
+// Messages are ordered from the root to the given message
+func (m *PostgresMessages) ReadBranch(messageID string) ([]chat.Message, error) {
+	var messages []chat.Message
+	branchQuery := `
+	WITH RECURSIVE branch (branch_id, depth) AS (
+		SELECT id, 0 FROM messages WHERE id = $1
+		UNION ALL
+		SELECT messages.parent_id, branch.depth + 1
+		FROM messages JOIN branch ON messages.id = branch.branch_id
+		WHERE messages.parent_id IS NOT NULL
+	)
+	SELECT ` + messageColumns + `
+	FROM messages JOIN branch ON messages.id = branch.branch_id
+	ORDER BY branch.depth DESC
+	`
+	if err := m.db.Select(&messages, branchQuery, messageID); err != nil {
+		return nil, fmt.Errorf("failed to get branch for message %s: %w", messageID, err)
+	}
+
+	slog.Debug("read messages branch",
+		slog.String("id", messageID),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// Search returns messages matching the full text query, best matches first
+func (m *PostgresMessages) Search(query string, limit int) ([]chat.Message, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var messages []chat.Message
+	searchQuery := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE content_tsv @@ plainto_tsquery('simple', $1)
+	ORDER BY ts_rank(content_tsv, plainto_tsquery('simple', $1)) DESC
+	LIMIT $2
+	`
+	if err := m.db.Select(&messages, searchQuery, strings.Join(terms, " "), limit); err != nil {
+		return nil, fmt.Errorf("failed to search messages for %q: %w", query, err)
+	}
+
+	slog.Debug("search messages",
+		slog.String("query", query),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// Write writes new message to the storage
+func (m *PostgresMessages) Write(message chat.Message) error {
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin write message transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	// Lock the session row, so concurrent writers to the session take the next sequence numbers in turn
+	if _, err := tx.Exec("SELECT id FROM sessions WHERE id = $1 FOR UPDATE", message.SessionID); err != nil {
+		return fmt.Errorf("failed to lock session %s: %w", message.SessionID, err)
+	}
+
+	// Prepare the query to insert a new record with the next sequence number of the session,
+	// ignoring if it already exists
+	insertQuery := `
+	INSERT INTO messages (` + messageInsertColumns + `)
+	SELECT ` + messageInsertValues + ` FROM messages WHERE session_id = :session_id
+	ON CONFLICT (id) DO NOTHING
+	`
+	if _, err := tx.NamedExec(insertQuery, messageArgs(message)); err != nil {
+		return fmt.Errorf("failed to insert message %+v: %w", message, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit write message transaction: %w", err)
+	}
+
+	slog.Debug("message added to messages",
+		slog.String("id", message.ID),
+		slog.String("session_id", message.SessionID),
+		slog.String("parent_id", message.ParentID),
+		slog.String("content", message.Content),
+		slog.String("role", string(message.Role)),
+		slog.Time("timestamp", message.Timestamp),
+	)
+	return nil
+}
+
+// SetStarred stars or unstars the message
+func (m *PostgresMessages) SetStarred(id string, starred bool) error {
+	res, err := m.db.Exec("UPDATE messages SET starred = $1 WHERE id = $2", starred, id)
+	if err != nil {
+		return fmt.Errorf("failed to star message %s: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get message for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("message starred",
+		slog.String("id", id),
+		slog.Bool("starred", starred),
+	)
+	return nil
+}
+
+// Delete deletes the given message by id from the storage.
+// Its children are attached to its parent, so the branches stay connected
+func (m *PostgresMessages) Delete(id string) error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin delete message transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var message chat.Message
+
+	// retrieve the message's session_id and parent_id to reattach its children
+	err = tx.Get(&message, "SELECT "+messageColumns+" FROM messages WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to get message for id %s: %w", id, notFound(err))
+	}
+
+	parentID := nullString(message.ParentID)
+	if _, err := tx.Exec("UPDATE messages SET parent_id = $1 WHERE parent_id = $2", parentID, id); err != nil {
+		return fmt.Errorf("failed to reattach children of message %s: %w", id, err)
+	}
+	if _, err := tx.Exec("UPDATE sessions SET head_id = $1 WHERE head_id = $2", parentID, id); err != nil {
+		return fmt.Errorf("failed to move session head from message %s: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM messages WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete message by id %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete message transaction: %w", err)
+	}
+
+	slog.Debug("message deleted from messages",
+		slog.String("id", message.ID),
+		slog.String("session_id", message.SessionID),
+		slog.Time("timestamp", message.Timestamp),
+	)
+	return nil
+}
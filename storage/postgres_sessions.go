@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresSessions is a postgres storage for sessions
+type PostgresSessions struct {
+	db *sqlx.DB
+}
+
+// NewPostgresSessions creates a new PostgresSessions storage.
+// The sessions table is created by the schema migrations, see Migrate
+func NewPostgresSessions(db *sqlx.DB) *PostgresSessions {
+	return &PostgresSessions{db: db}
+}
+
+// Read returns all sessions
+func (s *PostgresSessions) Read() ([]chat.Session, error) {
+	var sessions []chat.Session
+	err := s.db.Select(&sessions, "SELECT "+sessionColumns+" FROM sessions ORDER BY timestamp DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	slog.Debug("read sessions",
+		slog.Int("count", len(sessions)),
+	)
+	return sessions, nil
+}
+
+// Get returns the session by id
+func (s *PostgresSessions) Get(id string) (chat.Session, error) {
+	var session chat.Session
+	if err := s.db.Get(&session, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id); err != nil {
+		return chat.Session{}, fmt.Errorf("failed to get session for id %s: %w", id, notFound(err))
+	}
+	return session, nil
+}
+
+// SetHead makes the message the head of the session's active branch
+func (s *PostgresSessions) SetHead(id, messageID string) error {
+	res, err := s.db.Exec("UPDATE sessions SET head_id = $1 WHERE id = $2", nullString(messageID), id)
+	if err != nil {
+		return fmt.Errorf("failed to set head %s for session %s: %w", messageID, id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("session head moved",
+		slog.String("id", id),
+		slog.String("head_id", messageID),
+	)
+	return nil
+}
+
+// Rename changes the name of the session
+func (s *PostgresSessions) Rename(id, name string) error {
+	res, err := s.db.Exec("UPDATE sessions SET name = $1 WHERE id = $2", name, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename session %s: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("session renamed",
+		slog.String("id", id),
+		slog.String("name", name),
+	)
+	return nil
+}
+
+// Write writes new session to the storage
+func (s *PostgresSessions) Write(session chat.Session) error {
+	if session.Timestamp.IsZero() {
+		session.Timestamp = time.Now()
+	}
+	// Prepare the query to insert a new record, ignoring if it already exists
+	insertQuery := "INSERT INTO sessions (id, name, head_id, timestamp) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING"
+	if _, err := s.db.Exec(insertQuery, session.ID, session.Name, nullString(session.HeadID), session.Timestamp); err != nil {
+		return fmt.Errorf("failed to insert session %+v: %w", session, err)
+	}
+
+	slog.Debug("session added to sessions",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Time("timestamp", session.Timestamp),
+	)
+	return nil
+}
+
+// Import writes the session with its messages in one transaction, the head is set only for a new session
+func (s *PostgresSessions) Import(session chat.Session, messages []chat.Message) error {
+	if session.Timestamp.IsZero() {
+		session.Timestamp = time.Now()
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin import session transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	// The head is set after the messages are written, it references one of them
+	res, err := tx.Exec("INSERT INTO sessions (id, name, timestamp) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING", session.ID, session.Name, session.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert session %+v: %w", session, err)
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check inserted session %s: %w", session.ID, err)
+	}
+
+	// Lock the session row, so concurrent writers to the session take the next sequence numbers in turn
+	if _, err := tx.Exec("SELECT id FROM sessions WHERE id = $1 FOR UPDATE", session.ID); err != nil {
+		return fmt.Errorf("failed to lock session %s: %w", session.ID, err)
+	}
+
+	insertQuery := `
+	INSERT INTO messages (` + messageInsertColumns + `)
+	SELECT ` + messageInsertValues + ` FROM messages WHERE session_id = :session_id
+	ON CONFLICT (id) DO NOTHING
+	`
+	for _, message := range messages {
+		if message.Timestamp.IsZero() {
+			message.Timestamp = time.Now()
+		}
+		if _, err := tx.NamedExec(insertQuery, messageArgs(message)); err != nil {
+			return fmt.Errorf("failed to insert message %+v: %w", message, err)
+		}
+	}
+
+	if created > 0 && session.HeadID != "" {
+		if _, err := tx.Exec("UPDATE sessions SET head_id = $1 WHERE id = $2", session.HeadID, session.ID); err != nil {
+			return fmt.Errorf("failed to set head %s for session %s: %w", session.HeadID, session.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import session transaction: %w", err)
+	}
+
+	slog.Debug("session imported",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Bool("created", created > 0),
+		slog.Int("messages", len(messages)),
+	)
+	return nil
+}
+
+// Delete deletes the given session by id together with its messages in one transaction
+func (s *PostgresSessions) Delete(id string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin delete session transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var session chat.Session
+
+	// retrieve the session's name and timestamp for logging purposes
+	err = tx.Get(&session, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to get session for id %s: %w", id, notFound(err))
+	}
+
+	res, err := tx.Exec("DELETE FROM messages WHERE session_id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages for session_id %s: %w", id, err)
+	}
+	deletedMessages, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count deleted messages for session_id %s: %w", id, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete session by id %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete session transaction: %w", err)
+	}
+
+	slog.Debug("session deleted from sessions",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Int64("deleted_messages", deletedMessages),
+	)
+	return nil
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/jmoiron/sqlx"
+)
+
+// SqliteMessages is a sqlite storage for messages
+type SqliteMessages struct {
+	db *sqlx.DB
+}
+
+// NewSqliteMessages creates a new SqliteMessages storage.
+// The messages table is created by the schema migrations, see Migrate
+func NewSqliteMessages(db *sqlx.DB) *SqliteMessages {
+	return &SqliteMessages{db: db}
+}
+
+// Read returns all messages
+func (m *SqliteMessages) Read() ([]chat.Message, error) {
+	var messages []chat.Message
+	err := m.db.Select(&messages, "SELECT "+messageColumns+" FROM messages ORDER BY session_id, seq ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	slog.Debug("read messages",
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadBySessionID returns messages for a specific session_id
+func (m *SqliteMessages) ReadBySessionID(sessionID string) ([]chat.Message, error) {
+	var messages []chat.Message
+	err := m.db.Select(&messages, "SELECT "+messageColumns+" FROM messages WHERE session_id = ? ORDER BY seq ASC", sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages for session_id %s: %w", sessionID, err)
+	}
+
+	slog.Debug("read messages by session_id",
+		slog.String("session_id", sessionID),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadStarred returns all starred messages
+func (m *SqliteMessages) ReadStarred() ([]chat.Message, error) {
+	var messages []chat.Message
+	err := m.db.Select(&messages, "SELECT "+messageColumns+" FROM messages WHERE starred = TRUE ORDER BY session_id, seq ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get starred messages: %w", err)
+	}
+
+	slog.Debug("read starred messages",
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// ReadBranch returns the branch ending at the given message, walking parents up to the root.
+// Messages are ordered from the root to the given message
+func (m *SqliteMessages) ReadBranch(messageID string) ([]chat.Message, error) {
+	var messages []chat.Message
+	branchQuery := `
+	WITH RECURSIVE branch (branch_id, depth) AS (
+		SELECT id, 0 FROM messages WHERE id = ?
+		UNION ALL
+		SELECT messages.parent_id, branch.depth + 1
+		FROM messages JOIN branch ON messages.id = branch.branch_id
+		WHERE messages.parent_id IS NOT NULL
+	)
+	SELECT ` + messageColumns + `
+	FROM messages JOIN branch ON messages.id = branch.branch_id
+	ORDER BY branch.depth DESC
+	`
+	if err := m.db.Select(&messages, branchQuery, messageID); err != nil {
+		return nil, fmt.Errorf("failed to get branch for message %s: %w", messageID, err)
+	}
+
+	slog.Debug("read messages branch",
+		slog.String("id", messageID),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// Search returns messages matching the full text query, best matches first
+func (m *SqliteMessages) Search(query string, limit int) ([]chat.Message, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	// Quote every term to keep fts5 query syntax out of user input
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+
+	var messages []chat.Message
+	searchQuery := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	JOIN (SELECT rowid AS fts_rowid, rank FROM messages_fts WHERE messages_fts MATCH ?) AS f
+		ON messages.rowid = f.fts_rowid
+	ORDER BY f.rank
+	LIMIT ?
+	`
+	if err := m.db.Select(&messages, searchQuery, strings.Join(terms, " "), limit); err != nil {
+		return nil, fmt.Errorf("failed to search messages for %q: %w", query, err)
+	}
+
+	slog.Debug("search messages",
+		slog.String("query", query),
+		slog.Int("count", len(messages)),
+	)
+	return messages, nil
+}
+
+// Write writes new message to the storage
+func (m *SqliteMessages) Write(message chat.Message) error {
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+	// Prepare the query to insert a new record with the next sequence number of the session,
+	// ignoring if it already exists
+	insertQuery := `
+	INSERT OR IGNORE INTO messages (` + messageInsertColumns + `)
+	SELECT ` + messageInsertValues + ` FROM messages WHERE session_id = :session_id
+	`
+	if _, err := m.db.NamedExec(insertQuery, messageArgs(message)); err != nil {
+		return fmt.Errorf("failed to insert message %+v: %w", message, err)
+	}
+
+	slog.Debug("message added to messages",
+		slog.String("id", message.ID),
+		slog.String("session_id", message.SessionID),
+		slog.String("parent_id", message.ParentID),
+		slog.String("content", message.Content),
+		slog.String("role", string(message.Role)),
+		slog.Time("timestamp", message.Timestamp),
+	)
+	return nil
+}
+
+// SetStarred stars or unstars the message
+func (m *SqliteMessages) SetStarred(id string, starred bool) error {
+	res, err := m.db.Exec("UPDATE messages SET starred = ? WHERE id = ?", starred, id)
+	if err != nil {
+		return fmt.Errorf("failed to star message %s: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get message for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("message starred",
+		slog.String("id", id),
+		slog.Bool("starred", starred),
+	)
+	return nil
+}
+
+// Delete deletes the given message by id from the storage.
+// Its children are attached to its parent, so the branches stay connected
+func (m *SqliteMessages) Delete(id string) error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin delete message transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var message chat.Message
+
+	// retrieve the message's session_id and parent_id to reattach its children
+	err = tx.Get(&message, "SELECT "+messageColumns+" FROM messages WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to get message for id %s: %w", id, notFound(err))
+	}
+
+	parentID := nullString(message.ParentID)
+	if _, err := tx.Exec("UPDATE messages SET parent_id = ? WHERE parent_id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to reattach children of message %s: %w", id, err)
+	}
+	if _, err := tx.Exec("UPDATE sessions SET head_id = ? WHERE head_id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to move session head from message %s: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM messages WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete message by id %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete message transaction: %w", err)
+	}
+
+	slog.Debug("message deleted from messages",
+		slog.String("id", message.ID),
+		slog.String("session_id", message.SessionID),
+		slog.Time("timestamp", message.Timestamp),
+	)
+	return nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/jmoiron/sqlx"
+)
+
+// SqliteSessions is a sqlite storage for sessions
+type SqliteSessions struct {
+	db *sqlx.DB
+}
+
+// NewSqliteSessions creates a new SqliteSessions storage.
+// The sessions table is created by the schema migrations, see Migrate
+func NewSqliteSessions(db *sqlx.DB) *SqliteSessions {
+	return &SqliteSessions{db: db}
+}
+
+// Read returns all sessions
+func (s *SqliteSessions) Read() ([]chat.Session, error) {
+	var sessions []chat.Session
+	err := s.db.Select(&sessions, "SELECT "+sessionColumns+" FROM sessions ORDER BY timestamp DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	slog.Debug("read sessions",
+		slog.Int("count", len(sessions)),
+	)
+	return sessions, nil
+}
+
+// Get returns the session by id
+func (s *SqliteSessions) Get(id string) (chat.Session, error) {
+	var session chat.Session
+	if err := s.db.Get(&session, "SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id); err != nil {
+		return chat.Session{}, fmt.Errorf("failed to get session for id %s: %w", id, notFound(err))
+	}
+	return session, nil
+}
+
+// SetHead makes the message the head of the session's active branch
+func (s *SqliteSessions) SetHead(id, messageID string) error {
+	res, err := s.db.Exec("UPDATE sessions SET head_id = ? WHERE id = ?", nullString(messageID), id)
+	if err != nil {
+		return fmt.Errorf("failed to set head %s for session %s: %w", messageID, id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("session head moved",
+		slog.String("id", id),
+		slog.String("head_id", messageID),
+	)
+	return nil
+}
+
+// Rename changes the name of the session
+func (s *SqliteSessions) Rename(id, name string) error {
+	res, err := s.db.Exec("UPDATE sessions SET name = ? WHERE id = ?", name, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename session %s: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to get session for id %s: %w", id, ErrNotFound)
+	}
+
+	slog.Debug("session renamed",
+		slog.String("id", id),
+		slog.String("name", name),
+	)
+	return nil
+}
+
+// Write writes new session to the storage
+func (s *SqliteSessions) Write(session chat.Session) error {
+	if session.Timestamp.IsZero() {
+		session.Timestamp = time.Now()
+	}
+	// Prepare the query to insert a new record, ignoring if it already exists
+	insertQuery := "INSERT OR IGNORE INTO sessions (id, name, head_id, timestamp) VALUES (?, ?, ?, ?)"
+	if _, err := s.db.Exec(insertQuery, session.ID, session.Name, nullString(session.HeadID), session.Timestamp); err != nil {
+		return fmt.Errorf("failed to insert session %+v: %w", session, err)
+	}
+
+	slog.Debug("session added to sessions",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Time("timestamp", session.Timestamp),
+	)
+	return nil
+}
+
+// Import writes the session with its messages in one transaction, the head is set only for a new session
+func (s *SqliteSessions) Import(session chat.Session, messages []chat.Message) error {
+	if session.Timestamp.IsZero() {
+		session.Timestamp = time.Now()
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin import session transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	// The head is set after the messages are written, it references one of them
+	res, err := tx.Exec("INSERT OR IGNORE INTO sessions (id, name, timestamp) VALUES (?, ?, ?)", session.ID, session.Name, session.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert session %+v: %w", session, err)
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check inserted session %s: %w", session.ID, err)
+	}
+
+	insertQuery := `
+	INSERT OR IGNORE INTO messages (` + messageInsertColumns + `)
+	SELECT ` + messageInsertValues + ` FROM messages WHERE session_id = :session_id
+	`
+	for _, message := range messages {
+		if message.Timestamp.IsZero() {
+			message.Timestamp = time.Now()
+		}
+		if _, err := tx.NamedExec(insertQuery, messageArgs(message)); err != nil {
+			return fmt.Errorf("failed to insert message %+v: %w", message, err)
+		}
+	}
+
+	if created > 0 && session.HeadID != "" {
+		if _, err := tx.Exec("UPDATE sessions SET head_id = ? WHERE id = ?", session.HeadID, session.ID); err != nil {
+			return fmt.Errorf("failed to set head %s for session %s: %w", session.HeadID, session.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import session transaction: %w", err)
+	}
+
+	slog.Debug("session imported",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Bool("created", created > 0),
+		slog.Int("messages", len(messages)),
+	)
+	return nil
+}
+
+// Delete deletes the given session by id together with its messages in one transaction
+func (s *SqliteSessions) Delete(id string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin delete session transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var session chat.Session
+
+	// retrieve the session's name and timestamp for logging purposes
+	err = tx.Get(&session, "SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to get session for id %s: %w", id, notFound(err))
+	}
+
+	res, err := tx.Exec("DELETE FROM messages WHERE session_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages for session_id %s: %w", id, err)
+	}
+	deletedMessages, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count deleted messages for session_id %s: %w", id, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete session by id %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete session transaction: %w", err)
+	}
+
+	slog.Debug("session deleted from sessions",
+		slog.String("id", session.ID),
+		slog.String("name", session.Name),
+		slog.Int64("deleted_messages", deletedMessages),
+	)
+	return nil
+}
@@ -1,11 +1,197 @@
 package storage
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gennadis/gigachatui/internal/chat"
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"  // postgres driver
 	_ "modernc.org/sqlite" // sqlite driver
 )
 
-// NewSqliteDB creates a new sqlite database
+const (
+	driverSqlite   = "sqlite"
+	driverPostgres = "postgres"
+
+	sqliteDSNPrefix = "sqlite://"
+
+	// sessionColumns lists the sessions table columns scanned into chat.Session
+	sessionColumns = "id, name, COALESCE(head_id, '') AS head_id, timestamp"
+	// fileColumns lists the files table columns scanned into chat.File
+	fileColumns = "id, filename, path, purpose, bytes, timestamp"
+	// messageColumns lists the messages table columns scanned into chat.Message
+	messageColumns = `id, session_id, COALESCE(parent_id, '') AS parent_id, seq, content, role, timestamp, model,
+	prompt_tokens AS "usage.prompt_tokens", completion_tokens AS "usage.completion_tokens",
+	total_tokens AS "usage.total_tokens", starred, function_call, function_name, functions_state_id, attachments, inlined_files, images`
+	// messageInsertColumns and messageInsertValues insert a message with the next sequence number
+	// of its session, the values are bound by messageArgs
+	messageInsertColumns = `id, session_id, parent_id, seq, content, role, timestamp, model,
+	prompt_tokens, completion_tokens, total_tokens, starred, function_call, function_name, functions_state_id, attachments, inlined_files, images`
+	messageInsertValues = `:id, :session_id, :parent_id, COALESCE(MAX(seq), 0) + 1, :content, :role, :timestamp, :model,
+	:prompt_tokens, :completion_tokens, :total_tokens, :starred, :function_call, :function_name, :functions_state_id, :attachments, :inlined_files, :images`
+)
+
+// ErrNotFound is returned when the requested record does not exist in the storage
+var ErrNotFound = errors.New("not found")
+
+// SessionStore is a storage for chat sessions
+type SessionStore interface {
+	// Read returns all sessions, newest first
+	Read() ([]chat.Session, error)
+	// Get returns the session by id
+	Get(id string) (chat.Session, error)
+	// SetHead makes the message the head of the session's active branch
+	SetHead(id, messageID string) error
+	// Rename changes the name of the session
+	Rename(id, name string) error
+	// Write writes new session to the storage, ignoring it if it already exists
+	Write(session chat.Session) error
+	// Import writes the session with its messages in one transaction, parents before children.
+	// Sessions and messages which are already stored are left untouched,
+	// the head is set only for a new session
+	Import(session chat.Session, messages []chat.Message) error
+	// Delete deletes the given session by id together with all its messages
+	Delete(id string) error
+}
+
+// MessageStore is a storage for chat messages
+type MessageStore interface {
+	// Read returns all messages grouped by session_id and ordered by sequence number
+	Read() ([]chat.Message, error)
+	// ReadBySessionID returns messages for a specific session_id ordered by sequence number
+	ReadBySessionID(sessionID string) ([]chat.Message, error)
+	// ReadBranch returns the branch ending at the given message, from the root to the message
+	ReadBranch(messageID string) ([]chat.Message, error)
+	// ReadStarred returns all starred messages grouped by session_id and ordered by sequence number
+	ReadStarred() ([]chat.Message, error)
+	// Search returns up to limit messages matching the full text query, best matches first
+	Search(query string, limit int) ([]chat.Message, error)
+	// Write writes new message to the storage assigning it the next sequence number
+	// of its session, ignoring the message if it already exists
+	Write(message chat.Message) error
+	// SetStarred stars or unstars the message
+	SetStarred(id string, starred bool) error
+	// Delete deletes the given message by id, attaching its children to its parent
+	Delete(id string) error
+}
+
+// FileStore is a storage for the files uploaded to the GigaChat files storage
+type FileStore interface {
+	// Read returns all uploaded files, newest first
+	Read() ([]chat.File, error)
+	// Write writes the uploaded file to the storage, replacing the file with the same id
+	Write(file chat.File) error
+	// Delete deletes the given file by id
+	Delete(id string) error
+}
+
+var (
+	_ SessionStore = (*SqliteSessions)(nil)
+	_ SessionStore = (*PostgresSessions)(nil)
+	_ SessionStore = (*MemorySessions)(nil)
+	_ MessageStore = (*SqliteMessages)(nil)
+	_ MessageStore = (*PostgresMessages)(nil)
+	_ MessageStore = (*MemoryMessages)(nil)
+	_ FileStore    = (*SqliteFiles)(nil)
+	_ FileStore    = (*PostgresFiles)(nil)
+	_ FileStore    = (*MemoryFiles)(nil)
+)
+
+// NewSqliteDB creates a new sqlite database.
+// Foreign keys are enforced on every connection, sqlite has them off by default.
+// Concurrent writers wait for the write lock instead of failing with SQLITE_BUSY
 func NewSqliteDB(file string) (*sqlx.DB, error) {
-	return sqlx.Connect("sqlite", file)
+	return sqlx.Connect(driverSqlite, file+sqliteConnParams(file))
+}
+
+// sqliteConnParams returns the connection parameters appended to the sqlite file name
+func sqliteConnParams(file string) string {
+	sep := "?"
+	if strings.Contains(file, "?") {
+		sep = "&"
+	}
+	return sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+// NewPostgresDB connects to the postgres database with the given DSN
+func NewPostgresDB(dsn string) (*sqlx.DB, error) {
+	return sqlx.Connect(driverPostgres, dsn)
+}
+
+// Open connects to the database selected by the DSN.
+// DSNs starting with postgres:// or postgresql:// select postgres,
+// anything else is treated as a sqlite file path with an optional sqlite:// prefix
+func Open(dsn string) (*sqlx.DB, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return NewPostgresDB(dsn)
+	}
+	return NewSqliteDB(strings.TrimPrefix(dsn, sqliteDSNPrefix))
+}
+
+// NewStores creates session and message stores matching the database driver
+func NewStores(db *sqlx.DB) (SessionStore, MessageStore, error) {
+	switch db.DriverName() {
+	case driverSqlite:
+		return NewSqliteSessions(db), NewSqliteMessages(db), nil
+	case driverPostgres:
+		return NewPostgresSessions(db), NewPostgresMessages(db), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported database driver %s", db.DriverName())
+	}
+}
+
+// NewFileStore creates the file store matching the database driver
+func NewFileStore(db *sqlx.DB) (FileStore, error) {
+	switch db.DriverName() {
+	case driverSqlite:
+		return NewSqliteFiles(db), nil
+	case driverPostgres:
+		return NewPostgresFiles(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %s", db.DriverName())
+	}
+}
+
+// notFound converts sql.ErrNoRows into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// nullString converts an empty string into SQL NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// messageArgs returns the named query arguments for the message row
+func messageArgs(message chat.Message) map[string]any {
+	return map[string]any{
+		"id":                 message.ID,
+		"session_id":         message.SessionID,
+		"parent_id":          nullString(message.ParentID),
+		"content":            message.Content,
+		"role":               message.Role,
+		"timestamp":          message.Timestamp,
+		"model":              message.Model,
+		"prompt_tokens":      message.Usage.PromptTokens,
+		"completion_tokens":  message.Usage.CompletionTokens,
+		"total_tokens":       message.Usage.TotalTokens,
+		"starred":            message.Starred,
+		"function_call":      message.FunctionCall,
+		"function_name":      message.FunctionName,
+		"functions_state_id": message.FunctionsStateID,
+		"attachments":        message.Attachments,
+		"inlined_files":      message.InlinedFiles,
+		"images":             message.Images,
+	}
+}
+
+// searchTerms splits the full text query into lowercase terms
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
 }
@@ -0,0 +1,301 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gennadis/gigachatui/internal/chat"
+	"github.com/gennadis/gigachatui/storage"
+	"github.com/gennadis/gigachatui/storage/storagetest"
+	"github.com/jmoiron/sqlx"
+)
+
+// appendMessage writes the message as a reply to the parent and moves the session head to it
+func appendMessage(t *testing.T, sessions storage.SessionStore, messages storage.MessageStore, sessionID, parentID, content string) chat.Message {
+	t.Helper()
+	message := chat.NewMessage(content, chat.RoleUser, sessionID)
+	message.ParentID = parentID
+	if err := messages.Write(*message); err != nil {
+		t.Fatalf("failed to write message: %v", err)
+	}
+	if err := sessions.SetHead(sessionID, message.ID); err != nil {
+		t.Fatalf("failed to set head: %v", err)
+	}
+	return *message
+}
+
+func TestSessions(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			sessions, messages := st.Sessions, st.Messages
+
+			session := chat.NewSession("first")
+			if err := sessions.Write(*session); err != nil {
+				t.Fatalf("failed to write session: %v", err)
+			}
+			if err := sessions.Rename(session.ID, "renamed"); err != nil {
+				t.Fatalf("failed to rename session: %v", err)
+			}
+			got, err := sessions.Get(session.ID)
+			if err != nil || got.Name != "renamed" {
+				t.Errorf("got session %+v, %v", got, err)
+			}
+
+			appendMessage(t, sessions, messages, session.ID, "", "hello")
+			if err := sessions.Delete(session.ID); err != nil {
+				t.Fatalf("failed to delete session: %v", err)
+			}
+			if _, err := sessions.Get(session.ID); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("got error %v for deleted session, want not found", err)
+			}
+			if left, _ := messages.Read(); len(left) != 0 {
+				t.Errorf("got %d messages of deleted session", len(left))
+			}
+
+			for _, err := range []error{
+				sessions.Rename("missing", "name"),
+				sessions.SetHead("missing", ""),
+				sessions.Delete("missing"),
+			} {
+				if !errors.Is(err, storage.ErrNotFound) {
+					t.Errorf("got error %v for missing session, want not found", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMessages(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			sessions, messages := st.Sessions, st.Messages
+			session := chat.NewSession("messages")
+			if err := sessions.Write(*session); err != nil {
+				t.Fatalf("failed to write session: %v", err)
+			}
+
+			root := appendMessage(t, sessions, messages, session.ID, "", "the quick brown fox")
+			middle := appendMessage(t, sessions, messages, session.ID, root.ID, "jumps over")
+			alternative := appendMessage(t, sessions, messages, session.ID, root.ID, "sleeps under")
+			head := appendMessage(t, sessions, messages, session.ID, middle.ID, "the lazy dog")
+
+			stored, err := messages.ReadBySessionID(session.ID)
+			if err != nil || len(stored) != 4 {
+				t.Fatalf("got messages %+v, %v", stored, err)
+			}
+			for i, m := range stored {
+				if m.Seq != int64(i+1) {
+					t.Errorf("message %d has seq %d", i, m.Seq)
+				}
+			}
+
+			branch, err := messages.ReadBranch(head.ID)
+			if err != nil || len(branch) != 3 || branch[1].ID != middle.ID {
+				t.Errorf("got branch %+v, %v", branch, err)
+			}
+
+			found, err := messages.Search("lazy", 10)
+			if err != nil || len(found) != 1 || found[0].ID != head.ID {
+				t.Errorf("got search results %+v, %v", found, err)
+			}
+
+			if err := messages.SetStarred(alternative.ID, true); err != nil {
+				t.Fatalf("failed to star message: %v", err)
+			}
+			starred, err := messages.ReadStarred()
+			if err != nil || len(starred) != 1 || starred[0].ID != alternative.ID {
+				t.Errorf("got starred messages %+v, %v", starred, err)
+			}
+
+			// Deleting a message reparents its children and moves the head pointing at it
+			if err := messages.Delete(middle.ID); err != nil {
+				t.Fatalf("failed to delete message: %v", err)
+			}
+			branch, err = messages.ReadBranch(head.ID)
+			if err != nil || len(branch) != 2 || branch[0].ID != root.ID {
+				t.Errorf("got branch %+v after delete, %v", branch, err)
+			}
+			if err := messages.Delete(head.ID); err != nil {
+				t.Fatalf("failed to delete message: %v", err)
+			}
+			got, err := sessions.Get(session.ID)
+			if err != nil || got.HeadID != root.ID {
+				t.Errorf("got head %q after deleting it, want %q", got.HeadID, root.ID)
+			}
+		})
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			st := open(t)
+			session := chat.NewSession("concurrent")
+			if err := st.Sessions.Write(*session); err != nil {
+				t.Fatalf("failed to write session: %v", err)
+			}
+
+			// Writers to the same session get distinct sequence numbers
+			const writers, perWriter = 4, 5
+			var wg sync.WaitGroup
+			errs := make(chan error, writers*perWriter)
+			for range writers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range perWriter {
+						errs <- st.Messages.Write(*chat.NewMessage("hi", chat.RoleUser, session.ID))
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("failed to write message: %v", err)
+				}
+			}
+
+			stored, err := st.Messages.ReadBySessionID(session.ID)
+			if err != nil || len(stored) != writers*perWriter {
+				t.Fatalf("got %d messages, %v", len(stored), err)
+			}
+			for i, m := range stored {
+				if m.Seq != int64(i+1) {
+					t.Errorf("message %d has seq %d", i, m.Seq)
+				}
+			}
+		})
+	}
+}
+
+func TestFiles(t *testing.T) {
+	for name, open := range storagetest.Backends() {
+		t.Run(name, func(t *testing.T) {
+			files := open(t).Files
+			now := time.Now()
+			older := chat.File{ID: "file-1", Filename: "a.txt", Path: "docs/a.txt", Purpose: "general", Bytes: 10, Timestamp: now.Add(-time.Minute)}
+			newer := chat.File{ID: "file-2", Filename: "b.png", Purpose: "general", Bytes: 20, Timestamp: now}
+			for _, f := range []chat.File{older, newer} {
+				if err := files.Write(f); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+			older.Bytes = 11
+			if err := files.Write(older); err != nil {
+				t.Fatalf("failed to rewrite file: %v", err)
+			}
+
+			read, err := files.Read()
+			if err != nil {
+				t.Fatalf("failed to read files: %v", err)
+			}
+			if len(read) != 2 || read[0].ID != "file-2" || read[1].Bytes != 11 || read[1].Path != "docs/a.txt" {
+				t.Errorf("got files %+v", read)
+			}
+
+			if err := files.Delete("file-1"); err != nil {
+				t.Fatalf("failed to delete file: %v", err)
+			}
+			if err := files.Delete("file-1"); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("got error %v deleting a missing file, want not found", err)
+			}
+		})
+	}
+}
+
+// orphanedDB makes a migrated sqlite database with a message of the session "kept",
+// two messages of the missing session "lost-1" and one of the missing session "lost-2".
+// The orphans are written by a connection with foreign keys off, as by an older version
+func orphanedDB(t *testing.T) (*sqlx.DB, time.Time) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.db")
+	db, err := storage.NewSqliteDB(file)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := storage.Migrate(db, false); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	kept := chat.Session{ID: "kept", Name: "kept", Timestamp: time.Now()}
+	if err := storage.NewSqliteSessions(db).Write(kept); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	unchecked, err := sqlx.Connect("sqlite", file)
+	if err != nil {
+		t.Fatalf("failed to open database without foreign keys: %v", err)
+	}
+	defer unchecked.Close()
+	messages := storage.NewSqliteMessages(unchecked)
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, sessionID := range []string{"kept", "lost-1", "lost-1", "lost-2"} {
+		message := chat.NewMessage(fmt.Sprint(i), chat.RoleUser, sessionID)
+		message.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if err := messages.Write(*message); err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+	}
+	return db, start
+}
+
+func TestOrphans(t *testing.T) {
+	t.Run("find", func(t *testing.T) {
+		db, _ := orphanedDB(t)
+		orphans, err := storage.FindOrphans(db)
+		want := []storage.Orphan{{SessionID: "lost-1", Messages: 2}, {SessionID: "lost-2", Messages: 1}}
+		if err != nil || fmt.Sprint(orphans) != fmt.Sprint(want) {
+			t.Errorf("got orphans %v, %v, want %v", orphans, err, want)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		db, start := orphanedDB(t)
+		orphans, err := storage.FindOrphans(db)
+		if err != nil {
+			t.Fatalf("failed to find orphans: %v", err)
+		}
+		if err := storage.RestoreOrphans(db, orphans); err != nil {
+			t.Fatalf("failed to restore orphans: %v", err)
+		}
+
+		// Every message is kept and the restored sessions are dated by their earliest message
+		sessions := storage.NewSqliteSessions(db)
+		for id, timestamp := range map[string]time.Time{"lost-1": start.Add(time.Minute), "lost-2": start.Add(3 * time.Minute)} {
+			session, err := sessions.Get(id)
+			if err != nil || session.Name != "recovered "+id || !session.Timestamp.Equal(timestamp) {
+				t.Errorf("got session %+v, %v, want it dated %v", session, err, timestamp)
+			}
+		}
+		if stored, err := storage.NewSqliteMessages(db).Read(); err != nil || len(stored) != 4 {
+			t.Errorf("got %d messages, %v, want 4", len(stored), err)
+		}
+		if orphans, err := storage.FindOrphans(db); err != nil || len(orphans) != 0 {
+			t.Errorf("got orphans %v, %v after restoring", orphans, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		db, _ := orphanedDB(t)
+		deleted, err := storage.DeleteOrphans(db)
+		if err != nil || deleted != 3 {
+			t.Errorf("got %d deleted, %v, want 3", deleted, err)
+		}
+
+		// Only the message of the existing session is left
+		stored, err := storage.NewSqliteMessages(db).Read()
+		if err != nil || len(stored) != 1 || stored[0].SessionID != "kept" {
+			t.Errorf("got messages %+v, %v", stored, err)
+		}
+		if sessions, err := storage.NewSqliteSessions(db).Read(); err != nil || len(sessions) != 1 {
+			t.Errorf("got sessions %+v, %v, want only the kept one", sessions, err)
+		}
+	})
+}